@@ -0,0 +1,59 @@
+package authres
+
+// Builder builds an Authentication-Results header field.
+//
+//	s := authres.NewBuilder("mx.example.com").
+//		SPF(authres.ResultPass, "example.org").
+//		DKIM(authres.ResultPass, "example.org", "@example.org").
+//		String()
+type Builder struct {
+	identifier string
+	results    []Result
+}
+
+// NewBuilder creates a new builder for an Authentication-Results header field
+// with the provided authentication service identifier.
+func NewBuilder(identifier string) *Builder {
+	return &Builder{identifier: identifier}
+}
+
+// Add appends a result.
+func (b *Builder) Add(r Result) *Builder {
+	b.results = append(b.results, r)
+	return b
+}
+
+// Auth appends an SMTP AUTH result.
+func (b *Builder) Auth(value ResultValue, auth string) *Builder {
+	return b.Add(&AuthResult{Value: value, Auth: auth})
+}
+
+// DKIM appends a DKIM result.
+func (b *Builder) DKIM(value ResultValue, domain, identifier string) *Builder {
+	return b.Add(&DKIMResult{Value: value, Domain: domain, Identifier: identifier})
+}
+
+// IPRev appends an iprev result.
+func (b *Builder) IPRev(value ResultValue, ip string) *Builder {
+	return b.Add(&IPRevResult{Value: value, IP: ip})
+}
+
+// SPF appends an SPF result.
+func (b *Builder) SPF(value ResultValue, from string) *Builder {
+	return b.Add(&SPFResult{Value: value, From: from})
+}
+
+// DMARC appends a DMARC result.
+func (b *Builder) DMARC(value ResultValue, from string) *Builder {
+	return b.Add(&DMARCResult{Value: value, From: from})
+}
+
+// Results returns the results appended so far.
+func (b *Builder) Results() []Result {
+	return b.results
+}
+
+// String formats the Authentication-Results header field value.
+func (b *Builder) String() string {
+	return Format(b.identifier, b.results)
+}
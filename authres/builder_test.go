@@ -0,0 +1,27 @@
+package authres
+
+import (
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	s := NewBuilder("mx.example.com").
+		SPF(ResultPass, "sender@example.net").
+		DKIM(ResultPass, "example.net", "@example.net").
+		DMARC(ResultPass, "example.net").
+		String()
+
+	want := "mx.example.com;\r\n" +
+		"\tspf=pass smtp.mailfrom=sender@example.net;\r\n" +
+		"\tdkim=pass header.d=example.net header.i=@example.net;\r\n" +
+		"\tdmarc=pass header.from=example.net"
+	if s != want {
+		t.Errorf("Expected formatted header field to be \n%q\n but got \n%q", want, s)
+	}
+}
+
+func TestBuilder_empty(t *testing.T) {
+	if s := NewBuilder("mx.example.com").String(); s != "mx.example.com; none" {
+		t.Errorf("Expected formatted header field to be %q, but got %q", "mx.example.com; none", s)
+	}
+}
@@ -0,0 +1,122 @@
+package authres
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+)
+
+// CachingParser is a Parser which caches the parsed header fields, e.g. to
+// parse header fields which are repeated across many messages. The least
+// recently used header fields are evicted when the cache is full.
+//
+// Results are cloned, so that modifying them doesn't affect the cache. Header
+// fields containing custom results which don't implement Clone aren't cached,
+// since cloning them would return a *GenericResult, see RegisterMethod. Cached
+// header fields are parsed again after a method is registered or unregistered.
+// A CachingParser is safe for concurrent use.
+type CachingParser struct {
+	parser Parser
+	size   int
+
+	mu    sync.Mutex
+	lru   *list.List // of *cacheEntry, most recently used first
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	value  string
+	gen    uint64 // generation of the registered methods
+	parsed *Parsed
+}
+
+// NewCachingParser creates a new caching parser, caching at most size header
+// fields parsed with p. If p is nil, header fields are parsed as with Parse.
+// If size is zero or less, nothing is cached.
+func NewCachingParser(p *Parser, size int) *CachingParser {
+	c := &CachingParser{
+		size:  size,
+		lru:   list.New(),
+		items: make(map[string]*list.Element),
+	}
+	if p != nil {
+		c.parser = *p
+	}
+	return c
+}
+
+// Parse parses the provided Authentication-Results header field, as
+// Parser.Parse does. The returned error is Parsed.Error.
+func (c *CachingParser) Parse(v string) (*Parsed, error) {
+	gen := registryGeneration()
+	if parsed := c.get(v, gen); parsed != nil {
+		return parsed, parsed.Error
+	}
+
+	parsed := c.parser.parse(v)
+	if clone := parsed.Clone(); sameTypes(clone.Results, parsed.Results) {
+		c.add(v, gen, clone)
+	}
+	return parsed, parsed.Error
+}
+
+func (c *CachingParser) get(v string, gen uint64) *Parsed {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[v]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if entry.gen != gen {
+		// Parsed with other registered methods
+		c.lru.Remove(elem)
+		delete(c.items, v)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return entry.parsed.Clone()
+}
+
+func (c *CachingParser) add(v string, gen uint64, parsed *Parsed) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[v]; ok {
+		// Added concurrently
+		if entry := elem.Value.(*cacheEntry); entry.gen < gen {
+			entry.gen, entry.parsed = gen, parsed
+		}
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.items[v] = c.lru.PushFront(&cacheEntry{value: v, gen: gen, parsed: parsed})
+	if c.lru.Len() > c.size {
+		elem := c.lru.Back()
+		c.lru.Remove(elem)
+		delete(c.items, elem.Value.(*cacheEntry).value)
+	}
+}
+
+// sameTypes reports whether the results in a and b have the same types, e.g.
+// whether the results in b have been cloned as a.
+func sameTypes(a, b []Result) bool {
+	for i := range a {
+		if reflect.TypeOf(a[i]) != reflect.TypeOf(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the number of cached header fields.
+func (c *CachingParser) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,100 @@
+package authres
+
+import (
+	"testing"
+)
+
+func TestCachingParser(t *testing.T) {
+	c := NewCachingParser(&Parser{Strict: true}, 2)
+
+	v := "example.com; spf=pass smtp.mailfrom=example.net"
+	p1, err := c.Parse(v)
+	if err != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", v, err)
+	}
+	p1.Results[0].(*SPFResult).From = "modified.example"
+
+	p2, err := c.Parse(v)
+	if err != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", v, err)
+	}
+	if from := p2.Results[0].(*SPFResult).From; from != "example.net" {
+		t.Errorf("Parse(%q): cached result was modified, got smtp.mailfrom=%q", v, from)
+	}
+
+	malformed := "example.com; spf=pass header.d"
+	for i := 0; i < 2; i++ {
+		if _, err := c.Parse(malformed); err == nil {
+			t.Errorf("Parse(%q): expected an error", malformed)
+		}
+	}
+
+	c.Parse("example.com; dkim=pass")
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len() = %v, want 2", n)
+	}
+	if _, ok := c.items[v]; ok {
+		t.Errorf("Parse(): expected %q to be evicted", v)
+	}
+
+	c = NewCachingParser(nil, 0)
+	c.Parse(v)
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len() = %v, want 0", n)
+	}
+}
+
+func TestCachingParser_registeredMethods(t *testing.T) {
+	c := NewCachingParser(nil, 2)
+
+	v := "example.com; x-company-score=pass policy.score=4.5"
+	if p, _ := c.Parse(v); len(p.Results) != 1 {
+		t.Fatalf("Parse(%q): expected 1 result, got %v", v, p.Results)
+	} else if _, ok := p.Results[0].(*GenericResult); !ok {
+		t.Errorf("Parse(%q): expected a generic result, got %T", v, p.Results[0])
+	}
+
+	RegisterMethod("x-company-score", func() Result {
+		return new(scoreResult)
+	})
+	defer UnregisterMethod("x-company-score")
+
+	// scoreResult doesn't implement Clone, it must not be cached
+	for i := 0; i < 2; i++ {
+		if p, _ := c.Parse(v); len(p.Results) != 1 {
+			t.Fatalf("Parse(%q): expected 1 result, got %v", v, p.Results)
+		} else if _, ok := p.Results[0].(*scoreResult); !ok {
+			t.Errorf("Parse(%q): expected a custom result after RegisterMethod, got %T", v, p.Results[0])
+		}
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len() = %v, want 0", n)
+	}
+
+	UnregisterMethod("x-company-score")
+	if p, _ := c.Parse(v); len(p.Results) != 1 {
+		t.Fatalf("Parse(%q): expected 1 result, got %v", v, p.Results)
+	} else if _, ok := p.Results[0].(*GenericResult); !ok {
+		t.Errorf("Parse(%q): expected a generic result after UnregisterMethod, got %T", v, p.Results[0])
+	}
+}
+
+const benchmarkCacheHeader = "mx.example.com;\r\n" +
+	"\tdkim=pass header.i=@example.net header.s=selector header.b=AbCdEfGh;\r\n" +
+	"\tspf=pass smtp.mailfrom=sender@example.net;\r\n" +
+	"\tdmarc=pass (p=none sp=none dis=none) header.from=example.net"
+
+func BenchmarkParseRepeated(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Parse(benchmarkCacheHeader)
+	}
+}
+
+func BenchmarkCachingParserRepeated(b *testing.B) {
+	c := NewCachingParser(nil, 128)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Parse(benchmarkCacheHeader)
+	}
+}
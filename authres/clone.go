@@ -0,0 +1,112 @@
+package authres
+
+// Clone returns a deep copy of p. The error is shared, since errors are
+// immutable.
+func (p *Parsed) Clone() *Parsed {
+	c := *p
+	if p.Results != nil {
+		c.Results = make([]Result, len(p.Results))
+		for i, r := range p.Results {
+			c.Results[i] = r.Clone()
+		}
+	}
+	c.Comments = cloneSlice(p.Comments)
+	c.Unknown = cloneSlice(p.Unknown)
+	c.Warnings = cloneSlice(p.Warnings)
+	return &c
+}
+
+func (b *ResultBase) clone() ResultBase {
+	c := *b
+	c.Extra = cloneMap(b.Extra)
+	c.Policy = cloneMap(b.Policy)
+	return c
+}
+
+func cloneMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func cloneSlice[T any](l []T) []T {
+	if l == nil {
+		return nil
+	}
+	return append([]T(nil), l...)
+}
+
+func (r *AuthResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	return &c
+}
+
+func (r *DKIMResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	return &c
+}
+
+func (r *DomainKeysResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	return &c
+}
+
+func (r *IPRevResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	return &c
+}
+
+func (r *SenderIDResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	c.Headers = cloneSlice(r.Headers)
+	return &c
+}
+
+func (r *SPFResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	return &c
+}
+
+func (r *DMARCResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	return &c
+}
+
+func (r *DKIMADSPResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	return &c
+}
+
+func (r *BIMIResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	return &c
+}
+
+func (r *ARCResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	c.Chain = cloneSlice(r.Chain)
+	return &c
+}
+
+func (r *GenericResult) Clone() Result {
+	c := *r
+	c.ResultBase = r.ResultBase.clone()
+	c.Params = cloneMap(r.Params)
+	c.Properties = cloneSlice(r.Properties)
+	return &c
+}
@@ -0,0 +1,44 @@
+package authres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsed_Clone(t *testing.T) {
+	p := Parse("example.com; x-custom=pass header.d=example.org (comment);" +
+		" sender-id=pass header.from=example.org; spf=pass smtp.mailfrom=example.net policy.x=y")
+	if p.Error != nil {
+		t.Fatalf("Parse() = %v", p.Error)
+	}
+
+	c := p.Clone()
+	if !reflect.DeepEqual(p, c) {
+		t.Fatalf("Expected clone to be equal to the original, got %#v", c)
+	}
+	for i := range p.Results {
+		if p.Results[i] == c.Results[i] {
+			t.Errorf("Expected result #%v to be copied", i)
+		}
+	}
+
+	c.Results[0].(*GenericResult).Params["header.d"] = "example.com"
+	c.Results[0].(*GenericResult).Properties[0].Value = "example.com"
+	c.Results[1].(*SenderIDResult).Headers[0].Value = "example.com"
+	c.Results[2].base().Extra["policy.x"] = "z"
+	c.Comments[0] = "modified"
+
+	generic := p.Results[0].(*GenericResult)
+	if generic.Params["header.d"] != "example.org" || generic.Properties[0].Value != "example.org" {
+		t.Errorf("Expected original generic result to be unchanged, got %#v", generic)
+	}
+	if senderID := p.Results[1].(*SenderIDResult); senderID.Headers[0].Value != "example.org" {
+		t.Errorf("Expected original Sender-ID result to be unchanged, got %#v", senderID)
+	}
+	if extra := p.Results[2].base().Extra; extra["policy.x"] != "y" {
+		t.Errorf("Expected original extra params to be unchanged, got %v", extra)
+	}
+	if p.Comments[0] != "comment" {
+		t.Errorf("Expected original comments to be unchanged, got %v", p.Comments)
+	}
+}
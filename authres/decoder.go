@@ -0,0 +1,138 @@
+package authres
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/textproto"
+	"strings"
+)
+
+// fieldNames contains the names of the header fields containing
+// authentication results.
+var fieldNames = []string{
+	"Authentication-Results",
+	"ARC-Authentication-Results",
+}
+
+func isFieldName(name string) bool {
+	for _, k := range fieldNames {
+		if strings.EqualFold(name, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnknownField is returned by ParseField when the header field isn't an
+// Authentication-Results or ARC-Authentication-Results header field.
+var ErrUnknownField = errors.New("msgauth: not an authentication results header field")
+
+// ParseField parses a whole Authentication-Results or
+// ARC-Authentication-Results header field, including its name, e.g.
+// "Authentication-Results: example.org; none". The field name is
+// case-insensitive.
+//
+// The returned error is either ErrUnknownField or Parsed.Error. The offsets of
+// parse errors are relative to the field value.
+func ParseField(field string) (*Parsed, error) {
+	name, value, ok := strings.Cut(field, ":")
+	if !ok || !isFieldName(strings.TrimSpace(name)) {
+		return nil, ErrUnknownField
+	}
+	p := Parse(value)
+	return p, p.Error
+}
+
+// ParseFromHeader parses all the Authentication-Results and
+// ARC-Authentication-Results header fields of h, e.g. the Header of a
+// net/mail.Message. The fields of each name are returned in order of
+// appearance, Authentication-Results first: h doesn't record the order of
+// fields with different names. Errors are reported as in ParseMultiple. If h
+// doesn't contain any of these fields, an empty slice and a nil error are
+// returned.
+func ParseFromHeader(h textproto.MIMEHeader) ([]*Parsed, error) {
+	var values []string
+	for _, k := range fieldNames {
+		values = append(values, h.Values(k)...)
+	}
+	return ParseMultiple(values)
+}
+
+// Decoder reads authentication results from a message header.
+type Decoder struct {
+	r    *bufio.Reader
+	done bool
+}
+
+// NewDecoder creates a new decoder reading a message header from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads and parses the next Authentication-Results or
+// ARC-Authentication-Results header field. Other header fields are skipped. It
+// returns io.EOF when the end of the header is reached.
+//
+// Errors which occur while parsing the header field are reported in
+// Parsed.Error.
+func (d *Decoder) Decode() (*Parsed, error) {
+	for {
+		field, err := d.readField()
+		if err != nil {
+			return nil, err
+		}
+
+		name, value, ok := strings.Cut(field, ":")
+		if ok && isFieldName(strings.TrimSpace(name)) {
+			return Parse(value), nil
+		}
+	}
+}
+
+// readField reads a header field, including its continuation lines. Line
+// breaks are kept, they're removed by Parse.
+func (d *Decoder) readField() (string, error) {
+	if d.done {
+		return "", io.EOF
+	}
+
+	field, err := d.readLine()
+	if err != nil || field == "" {
+		// A blank line marks the end of the header
+		d.done = true
+		if err == nil {
+			err = io.EOF
+		}
+		return "", err
+	}
+
+	for {
+		b, err := d.r.Peek(1)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if b[0] != ' ' && b[0] != '\t' {
+			break
+		}
+
+		l, err := d.readLine()
+		if err != nil {
+			return "", err
+		}
+		field += "\r\n" + l
+	}
+
+	return field, nil
+}
+
+// readLine reads a line, without its trailing CRLF.
+func (d *Decoder) readLine() (string, error) {
+	l, err := d.r.ReadString('\n')
+	if err == io.EOF && l != "" {
+		err = nil
+	}
+	return strings.TrimRight(l, "\r\n"), err
+}
@@ -0,0 +1,162 @@
+package authres
+
+import (
+	"errors"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+const decoderTestHeader = "Received: from mx.example.org\r\n" +
+	"Authentication-Results: mx.example.com;\r\n" +
+	"\tspf=pass smtp.mailfrom=example.net\r\n" +
+	"ARC-Authentication-Results: i=1; relay.example.org;\r\n" +
+	" dkim=pass header.d=example.net\r\n" +
+	"Subject: Authentication-Results: not a result\r\n" +
+	"authentication-results: mx.example.com; dmarc=pass header.from=example.net\r\n" +
+	"\r\n" +
+	"Authentication-Results: body.example.com; none\r\n"
+
+func TestDecoder(t *testing.T) {
+	d := NewDecoder(strings.NewReader(decoderTestHeader))
+
+	var l []*Parsed
+	for {
+		p, err := d.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Decode() = %v", err)
+		}
+		l = append(l, p)
+	}
+
+	if len(l) != 3 {
+		t.Fatalf("Expected 3 header fields, got %v", len(l))
+	}
+	for i, p := range l {
+		if p.Error != nil {
+			t.Errorf("Expected no error for header field #%v, got %v", i, p.Error)
+		}
+		if len(p.Results) != 1 {
+			t.Errorf("Expected one result for header field #%v, got %v", i, p.Results)
+		}
+	}
+	if _, ok := First[*SPFResult](l[0]); !ok || l[0].Identifier != "mx.example.com" {
+		t.Errorf("Expected SPF result from mx.example.com, got %v from %q", l[0].Results, l[0].Identifier)
+	}
+	if _, ok := First[*DKIMResult](l[1]); !ok || l[1].Instance != 1 || l[1].Identifier != "relay.example.org" {
+		t.Errorf("Expected DKIM result from relay.example.org instance 1, got %v from %q instance %v", l[1].Results, l[1].Identifier, l[1].Instance)
+	}
+	if _, ok := First[*DMARCResult](l[2]); !ok {
+		t.Errorf("Expected DMARC result, got %v", l[2].Results)
+	}
+
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the end of the header, got %v", err)
+	}
+}
+
+func TestDecoder_noTrailingNewline(t *testing.T) {
+	d := NewDecoder(strings.NewReader("Authentication-Results: mx.example.com; spf=pass"))
+
+	p, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if p.Identifier != "mx.example.com" || len(p.Results) != 1 {
+		t.Errorf("Expected one result from mx.example.com, got %v from %q", p.Results, p.Identifier)
+	}
+
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestDecoder_foldedQuotedString(t *testing.T) {
+	field := "Authentication-Results: mx.example.com;\r\n" +
+		"\tdkim=fail reason=\"bad\r\n  sig\" header.d=example.net"
+	d := NewDecoder(strings.NewReader(field + "\r\n\r\n"))
+
+	p, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	want, err := ParseField(field)
+	if err != nil {
+		t.Fatalf("ParseField() = %v", err)
+	}
+	r, ok := First[*DKIMResult](p)
+	if !ok {
+		t.Fatalf("Expected a DKIM result, got %v", p.Results)
+	}
+	if wantReason := want.Results[0].(*DKIMResult).Reason; r.Reason != wantReason {
+		t.Errorf("Expected reason %q, got %q", wantReason, r.Reason)
+	}
+	if raw := "\tdkim=fail reason=\"bad\r\n  sig\" header.d=example.net"; !strings.HasSuffix(r.Raw, raw) {
+		t.Errorf("Expected raw result %q, got %q", raw, r.Raw)
+	}
+}
+
+func TestParseField(t *testing.T) {
+	p, err := ParseField("authentication-results: mx.example.com; spf=pass smtp.mailfrom=example.net")
+	if err != nil {
+		t.Fatalf("ParseField() = %v", err)
+	}
+	if p.Identifier != "mx.example.com" || len(p.Results) != 1 {
+		t.Errorf("Expected one result from mx.example.com, got %v from %q", p.Results, p.Identifier)
+	}
+
+	p, err = ParseField("ARC-Authentication-Results: i=1; relay.example.org; arc=none")
+	if err != nil {
+		t.Fatalf("ParseField() = %v", err)
+	}
+	if p.Instance != 1 || p.Identifier != "relay.example.org" {
+		t.Errorf("Expected instance 1 from relay.example.org, got %v from %q", p.Instance, p.Identifier)
+	}
+
+	for _, field := range []string{"Received: from mx.example.org", "mx.example.com; none"} {
+		if _, err := ParseField(field); !errors.Is(err, ErrUnknownField) {
+			t.Errorf("ParseField(%q) = %v, want %v", field, err, ErrUnknownField)
+		}
+	}
+
+	if _, err := ParseField("Authentication-Results: example.com 2; none"); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected error %v, got %v", ErrUnsupportedVersion, err)
+	}
+}
+
+func TestParseFromHeader(t *testing.T) {
+	msg, err := mail.ReadMessage(strings.NewReader(decoderTestHeader))
+	if err != nil {
+		t.Fatalf("ReadMessage() = %v", err)
+	}
+
+	parsed, err := ParseFromHeader(textproto.MIMEHeader(msg.Header))
+	if err != nil {
+		t.Fatalf("ParseFromHeader() = %v", err)
+	}
+	want := []struct {
+		identifier string
+		instance   int
+	}{
+		{"mx.example.com", 0},
+		{"mx.example.com", 0},
+		{"relay.example.org", 1},
+	}
+	if len(parsed) != len(want) {
+		t.Fatalf("ParseFromHeader() returned %v header fields, expected %v", len(parsed), len(want))
+	}
+	for i, w := range want {
+		if parsed[i].Identifier != w.identifier || parsed[i].Instance != w.instance {
+			t.Errorf("ParseFromHeader()[%v] = %q (instance %v), expected %q (instance %v)", i, parsed[i].Identifier, parsed[i].Instance, w.identifier, w.instance)
+		}
+	}
+
+	parsed, err = ParseFromHeader(textproto.MIMEHeader{"Subject": {"Hello"}})
+	if err != nil || parsed == nil || len(parsed) != 0 {
+		t.Errorf("ParseFromHeader() = %v, %v, expected an empty slice", parsed, err)
+	}
+}
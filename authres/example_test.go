@@ -16,10 +16,10 @@ func Example() {
 	log.Println(s)
 
 	// Parse
-	identifier, results, err := authres.Parse(s)
-	if err != nil {
-		log.Fatal(err)
+	parsed := authres.Parse(s)
+	if parsed.Error != nil {
+		log.Fatal(parsed.Error)
 	}
 
-	log.Println(identifier, results)
+	log.Println(parsed.Identifier, parsed.Results)
 }
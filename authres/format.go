@@ -1,30 +1,224 @@
 package authres
 
 import (
+	"io"
 	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 )
 
-// Format formats an Authentication-Results header.
+// maxLineLen is the line length after which Format folds the header field.
+const maxLineLen = 78
+
+// Format formats an Authentication-Results header field value. Results are
+// separated by semicolons, and are folded on separate lines when a line would
+// exceed 78 characters, including the "Authentication-Results: " field name on
+// the first line.
 func Format(identity string, results []Result) string {
-	s := identity
+	return FormatWithOptions(identity, results, nil)
+}
+
+// FormatOptions contains options for formatting a header field value.
+type FormatOptions struct {
+	// Fold enables folding: results are placed on separate lines when a line
+	// would exceed MaxLineLength. Lines are only folded between results.
+	Fold bool
+	// MaxLineLength is the line length after which the header field is
+	// folded. If zero, it defaults to 78. The first line includes the field
+	// name and the colon, even though they aren't written.
+	MaxLineLength int
+	// FieldName is the name of the header field, counted in the length of the
+	// first line. If empty, it defaults to "Authentication-Results", or to
+	// "ARC-Authentication-Results" if Instance is non-zero.
+	FieldName string
+	// Instance is the ARC instance of an ARC-Authentication-Results header
+	// field. If non-zero, the header field value is prefixed with the
+	// instance tag, e.g. "i=1; ".
+	Instance int
+}
+
+var defaultFormatOptions = FormatOptions{Fold: true, MaxLineLength: maxLineLen}
 
+// FormatWithOptions is like Format, but with custom options. If opts is nil,
+// the default options are used, as with Format.
+func FormatWithOptions(identity string, results []Result, opts *FormatOptions) string {
+	var b strings.Builder
+	WriteToWithOptions(&b, identity, results, opts)
+	return b.String()
+}
+
+// WriteTo writes an Authentication-Results header field value to w, without
+// the field name. The value is formatted as with Format. It returns the number
+// of bytes written.
+func WriteTo(w io.Writer, identity string, results []Result) (int64, error) {
+	return WriteToWithOptions(w, identity, results, nil)
+}
+
+// WriteToWithOptions is like WriteTo, but with custom options. If opts is nil,
+// the default options are used, as with WriteTo.
+func WriteToWithOptions(w io.Writer, identity string, results []Result, opts *FormatOptions) (int64, error) {
+	if opts == nil {
+		opts = &defaultFormatOptions
+	}
+	maxLen := opts.MaxLineLength
+	if maxLen == 0 {
+		maxLen = maxLineLen
+	}
+
+	var n int64
+	write := func(s string) error {
+		m, err := io.WriteString(w, s)
+		n += int64(m)
+		return err
+	}
+
+	// Control characters could inject line breaks in the header field
+	identity, _ = sanitizeValue(identity)
+
+	name, prefix := fieldNames[0], ""
+	if opts.Instance != 0 {
+		name = fieldNames[1]
+		prefix = "i=" + formatInstance(opts.Instance) + "; "
+	}
+	if opts.FieldName != "" {
+		name = opts.FieldName
+	}
+	if err := write(prefix + identity); err != nil {
+		return n, err
+	}
 	if len(results) == 0 {
-		s += "; none"
-		return s
+		return n, write("; none")
 	}
 
+	lineLen := len(name) + len(": ") + len(prefix) + len(identity)
 	for _, r := range results {
-		method := resultMethod(r)
-		value, params := r.format()
+		res := formatResult(r)
+		sep := "; "
+		if opts.Fold && lineLen+len(sep)+len(res) > maxLen {
+			sep = ";\r\n\t"
+			lineLen = len("\t") + len(res)
+		} else {
+			lineLen += len(sep) + len(res)
+		}
+		if err := write(sep + res); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
 
-		s += "; " + method + "=" + string(value) + " " + formatParams(params)
+// Merge adds newResults to the Authentication-Results header field value
+// existing, and returns the formatted header field value. Results from
+// different authentication services are never merged: if existing is empty,
+// malformed or has an identifier other than identifier, a new header field
+// value containing only newResults is returned, and existing should be kept as
+// a separate header field. Identifiers are compared as in FilterByIdentifier.
+func Merge(existing string, identifier string, newResults []Result) string {
+	p := Parse(existing)
+	if p.Error != nil || !p.IdentifierMatches(identifier) {
+		return Format(identifier, newResults)
 	}
 
+	results := make([]Result, 0, len(p.Results)+len(newResults))
+	results = append(append(results, p.Results...), newResults...)
+	return FormatWithOptions(p.Identifier, results, &FormatOptions{
+		Fold:     true,
+		Instance: p.Instance,
+	})
+}
+
+// formatResult formats a single result, e.g. "spf=pass smtp.mailfrom=example.org".
+func formatResult(r Result) string {
+	value, params := resultParams(r)
+
+	method, _ := sanitizeValue(formatMethod(r))
+	s := method + "=" + formatValue(string(value))
+	if c := r.base().Comment; c != "" {
+		s += " " + formatComment(c)
+	}
+	if p := formatParams(params); p != "" {
+		s += " " + p
+	}
 	return s
 }
 
+// The String methods of results format them as in a header field, e.g.
+// "spf=pass smtp.mailfrom=example.org".
+
+func (r *AuthResult) String() string {
+	return formatResult(r)
+}
+
+func (r *DKIMResult) String() string {
+	return formatResult(r)
+}
+
+func (r *DomainKeysResult) String() string {
+	return formatResult(r)
+}
+
+func (r *IPRevResult) String() string {
+	return formatResult(r)
+}
+
+func (r *SenderIDResult) String() string {
+	return formatResult(r)
+}
+
+func (r *SPFResult) String() string {
+	return formatResult(r)
+}
+
+func (r *DMARCResult) String() string {
+	return formatResult(r)
+}
+
+func (r *DKIMADSPResult) String() string {
+	return formatResult(r)
+}
+
+func (r *BIMIResult) String() string {
+	return formatResult(r)
+}
+
+func (r *ARCResult) String() string {
+	return formatResult(r)
+}
+
+func (r *GenericResult) String() string {
+	return formatResult(r)
+}
+
+// formatMethod returns the method of r, including its version if any.
+func formatMethod(r Result) string {
+	method := resultMethod(r)
+	if v := r.base().formatVersion(); v != 0 {
+		method += "/" + strconv.Itoa(v)
+	}
+	return method
+}
+
+// resultParams returns the value and params of r, including its extra params.
+func resultParams(r Result) (ResultValue, map[string]string) {
+	value, params := r.format()
+	extra, policy := r.base().Extra, r.base().Policy
+	if len(extra) > 0 || len(policy) > 0 {
+		merged := make(map[string]string, len(params)+len(extra)+len(policy))
+		for k, v := range policy {
+			merged[string(PropertyPolicy)+"."+k] = v
+		}
+		for k, v := range extra {
+			merged[k] = v
+		}
+		for k, v := range params {
+			merged[k] = v
+		}
+		params = merged
+	}
+	return value, params
+}
+
 func resultMethod(r Result) string {
 	switch r := r.(type) {
 	case *AuthResult:
@@ -41,8 +235,14 @@ func resultMethod(r Result) string {
 		return "spf"
 	case *DMARCResult:
 		return "dmarc"
-	case *GenericResult:
-		return r.Method
+	case *DKIMADSPResult:
+		return "dkim-adsp"
+	case *BIMIResult:
+		return "bimi"
+	case *ARCResult:
+		return "arc"
+	case genericResult:
+		return strings.ToLower(r.generic().Method)
 	default:
 		return ""
 	}
@@ -56,20 +256,21 @@ func formatParams(params map[string]string) string {
 		}
 		keys = append(keys, k)
 	}
-	sort.Strings(keys)
+	sort.Slice(keys, func(i, j int) bool {
+		return lessParam(keys[i], keys[j])
+	})
 	if params["reason"] != "" {
 		keys = append([]string{"reason"}, keys...)
 	}
 
-	s := ""
-	i := 0
+	var b strings.Builder
 	for _, k := range keys {
 		if params[k] == "" {
 			continue
 		}
 
-		if i > 0 {
-			s += " "
+		if b.Len() > 0 {
+			b.WriteByte(' ')
 		}
 
 		var value string
@@ -78,11 +279,36 @@ func formatParams(params map[string]string) string {
 		} else {
 			value = formatPvalue(params[k])
 		}
-		s += k + "=" + value
-		i++
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(value)
 	}
 
-	return s
+	return b.String()
+}
+
+// lessParam reports whether the param a is formatted before b. Params are
+// sorted by property type, then by property name, case-insensitively, so that
+// the output is deterministic.
+func lessParam(a, b string) bool {
+	ta, na, _ := strings.Cut(a, ".")
+	tb, nb, _ := strings.Cut(b, ".")
+	if ta, tb := strings.ToLower(ta), strings.ToLower(tb); ta != tb {
+		return ta < tb
+	}
+	if na, nb := strings.ToLower(na), strings.ToLower(nb); na != nb {
+		return na < nb
+	}
+	return a < b
+}
+
+var commentEscaper = strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+
+// formatComment formats a comment. Control characters are replaced with
+// spaces, so that a comment can't inject a line break in the header field.
+func formatComment(s string) string {
+	s, _ = sanitizeValue(s)
+	return "(" + commentEscaper.Replace(s) + ")"
 }
 
 var tspecials = map[rune]struct{}{
@@ -101,19 +327,24 @@ func formatValue(s string) string {
 	//               ; Must be in quoted-string,
 	//               ; to use within parameter values
 
+	// Control characters can't be escaped in a quoted-string
+	s, _ = sanitizeValue(s)
+
 	shouldQuote := false
 	for _, ch := range s {
-		if _, special := tspecials[ch]; ch <= ' ' /* SPACE or CTL */ || special {
+		if _, special := tspecials[ch]; ch <= ' ' || ch == 0x7f /* SPACE or CTL */ || special {
 			shouldQuote = true
 		}
 	}
 
 	if shouldQuote {
-		return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+		return `"` + quotedStringEscaper.Replace(s) + `"`
 	}
 	return s
 }
 
+var quotedStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
 var addressOk = map[rune]struct{}{
 	// Most ASCII punctuation except for:
 	//  ( ) = "
@@ -140,15 +371,23 @@ func formatPvalue(s string) string {
 	// Relevant ABNF rules are much complicated than that, but this
 	// will catch most of the cases and we can fallback to quoting
 	// for others.
-	addressLike := true
-	for _, ch := range s {
-		if _, ok := addressOk[ch]; !unicode.IsLetter(ch) && !unicode.IsDigit(ch) && !ok {
-			addressLike = false
-		}
+	if isAddressLike(s) {
+		return s
 	}
 
-	if addressLike {
+	// Addresses with a quoted local part, e.g. "\"john@home\"@example.org",
+	// are valid pvalues
+	if i := indexAddressAt(s); i > 0 && i < len(s)-1 && isQuotedString(s[:i]) && isAddressLike(s[i+1:]) {
 		return s
 	}
 	return formatValue(s)
 }
+
+func isAddressLike(s string) bool {
+	for _, ch := range s {
+		if _, ok := addressOk[ch]; !unicode.IsLetter(ch) && !unicode.IsDigit(ch) && !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,10 @@
 package authres
 
 import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -12,3 +16,310 @@ func TestFormat(t *testing.T) {
 		}
 	}
 }
+
+var formatTests = []msgauthTest{
+	{
+		value: "example.com;" +
+			" x-custom=pass;\r\n" +
+			"\tdkim=pass header.d=example.org",
+		identifier: "example.com",
+		results: []Result{
+			&GenericResult{Method: "X-Custom", Value: ResultPass},
+			&DKIMResult{Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value: "mx.example.com;\r\n" +
+			"\tspf=pass smtp.mailfrom=sender@example.net;\r\n" +
+			"\tdkim=pass header.d=example.net header.i=@example.net;\r\n" +
+			"\tdmarc=pass header.from=example.net",
+		identifier: "mx.example.com",
+		results: []Result{
+			&SPFResult{Value: ResultPass, From: "sender@example.net"},
+			&DKIMResult{Value: ResultPass, Domain: "example.net", Identifier: "@example.net"},
+			&DMARCResult{Value: ResultPass, From: "example.net"},
+		},
+	}, {
+		value: "example.com" +
+			";\r\n\tsender-id=pass header.from=example.org header.sender=list@example.org",
+		identifier: "example.com",
+		results: []Result{
+			&SenderIDResult{Value: ResultPass, Headers: []Property{
+				{PropertyHeader, "from", "example.org"},
+				{PropertyHeader, "sender", "list@example.org"},
+			}},
+		},
+	},
+}
+
+func TestFormat_fold(t *testing.T) {
+	for _, test := range formatTests {
+		v := Format(test.identifier, test.results)
+		if v != test.value {
+			t.Errorf("Expected formatted header field to be \n%q\n but got \n%q", test.value, v)
+		}
+	}
+}
+
+func TestFormat_genericOrder(t *testing.T) {
+	r := &GenericResult{
+		Method: "x-custom",
+		Value:  ResultPass,
+		Params: map[string]string{
+			"x.c":      "3",
+			"x-a.b":    "4",
+			"Header.S": "2",
+			"dns.sec":  "yes",
+			"header.d": "1",
+			"reason":   "ok",
+		},
+	}
+	want := "x-custom=pass reason=ok dns.sec=yes header.d=1 Header.S=2 x.c=3 x-a.b=4"
+	for i := 0; i < 10; i++ {
+		if s := r.String(); s != want {
+			t.Fatalf("String() = %q, expected %q", s, want)
+		}
+	}
+}
+
+func TestFormat_commentControlChars(t *testing.T) {
+	r := &DKIMResult{
+		ResultBase: ResultBase{Comment: "ok\r\nBcc: evil@example.net\x00"},
+		Value:      ResultPass,
+	}
+	s := Format("example.com", []Result{r})
+	if want := "example.com; dkim=pass (ok  Bcc: evil@example.net )"; s != want {
+		t.Errorf("Format() = %q, expected %q", s, want)
+	}
+}
+
+func TestFormat_controlChars(t *testing.T) {
+	results := []Result{
+		&DKIMResult{Value: ResultFail, Reason: "bad\r\nX-Injected: yes"},
+		&SPFResult{Value: ResultPass, From: "\"john\r\n\"@example.net"},
+		&GenericResult{Method: "x-evil\r\nX-Injected", Value: "pass\r\n"},
+	}
+	s := Format("example.com\r\nX-Injected: yes", results)
+	if strings.ContainsAny(strings.ReplaceAll(s, "\r\n\t", ""), "\r\n") {
+		t.Fatalf("Format() = %q, expected no line break other than folding", s)
+	}
+
+	s = Format("example.com", results[:2])
+	p := Parse(s)
+	if p.Error != nil || len(p.Results) != 2 {
+		t.Fatalf("Parse(%q) = %v, %v, expected 2 results", s, p.Results, p.Error)
+	}
+	if reason := p.Results[0].(*DKIMResult).Reason; reason != "bad  X-Injected: yes" {
+		t.Errorf("Parse(Format()): got reason %q, expected %q", reason, "bad  X-Injected: yes")
+	}
+	if from := p.Results[1].(*SPFResult).From; from != "\"john  \"@example.net" {
+		t.Errorf("Parse(Format()): got smtp.mailfrom %q", from)
+	}
+}
+
+func TestFormat_quote(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   string
+	}{
+		{"ok", "reason=ok"},
+		{"bad key", `reason="bad key"`},
+		{"a;b=c", `reason="a;b=c"`},
+		{`say "hi"`, `reason="say \"hi\""`},
+		{`C:\path`, `reason="C:\\path"`},
+		{`\"`, `reason="\\\""`},
+	}
+	for _, test := range tests {
+		r := &DKIMResult{Value: ResultFail, Reason: test.reason}
+		s := r.String()
+		if want := "dkim=fail " + test.want; s != want {
+			t.Errorf("String() with reason %q = %q, expected %q", test.reason, s, want)
+		}
+
+		p := Parse(Format("example.com", []Result{r}))
+		if p.Error != nil || len(p.Results) != 1 {
+			t.Errorf("Parse(Format()) with reason %q: unexpected result %v, %v", test.reason, p.Results, p.Error)
+		} else if reason := p.Results[0].(*DKIMResult).Reason; reason != test.reason {
+			t.Errorf("Parse(Format()) with reason %q: got reason %q", test.reason, reason)
+		}
+	}
+}
+
+func TestFormat_roundTrip(t *testing.T) {
+	results := []Result{
+		&AuthResult{Value: ResultFail, Reason: "bad password", Auth: "sender@example.com"},
+		&DKIMResult{Value: ResultFail, Reason: "bad signature", Domain: "example.org", Identifier: "@example.org"},
+		&DomainKeysResult{Value: ResultPass, Domain: "example.org", From: "sender@example.org", Sender: "list@example.org"},
+		&IPRevResult{
+			ResultBase: ResultBase{Policy: map[string]string{"iprev": "192.0.2.1"}},
+			Value:      ResultPass,
+			IP:         "192.0.2.1",
+		},
+		&SenderIDResult{
+			Value:       ResultPass,
+			HeaderKey:   "from",
+			HeaderValue: "example.org",
+			Headers:     []Property{{PropertyHeader, "from", "example.org"}},
+		},
+		&SPFResult{Value: ResultSoftFail, From: "example.org", Helo: "mail.example.org"},
+		&DMARCResult{Value: ResultPass, From: "example.org"},
+	}
+
+	parsed := Parse(Format("example.com", results))
+	if parsed.Error != nil {
+		t.Fatalf("Parse(Format()) = %v", parsed.Error)
+	}
+	if parsed.Identifier != "example.com" {
+		t.Errorf("Expected identifier to be %q, but got %q", "example.com", parsed.Identifier)
+	}
+	if !reflect.DeepEqual(results, clearRaw(parsed.Results)) {
+		t.Errorf("Expected results to be \n%v\n but got \n%v", results, parsed.Results)
+	}
+}
+
+type limitedWriter struct {
+	n int
+}
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	if len(b) > w.n {
+		n := w.n
+		w.n = 0
+		return n, io.ErrShortWrite
+	}
+	w.n -= len(b)
+	return len(b), nil
+}
+
+func TestWriteTo(t *testing.T) {
+	for _, test := range append(msgauthTests, formatTests...) {
+		var b strings.Builder
+		n, err := WriteTo(&b, test.identifier, test.results)
+		if err != nil {
+			t.Fatalf("WriteTo() = %v", err)
+		}
+		if b.String() != test.value {
+			t.Errorf("Expected written header field to be \n%v\n but got \n%v", test.value, b.String())
+		}
+		if n != int64(b.Len()) {
+			t.Errorf("Expected WriteTo to return %v, got %v", b.Len(), n)
+		}
+	}
+
+	test := msgauthTests[len(msgauthTests)-1]
+	w := limitedWriter{n: 20}
+	if n, err := WriteTo(&w, test.identifier, test.results); err != io.ErrShortWrite || n != 20 {
+		t.Errorf("WriteTo() = %v, %v, want %v, %v", n, err, 20, io.ErrShortWrite)
+	}
+}
+
+func TestResult_String(t *testing.T) {
+	results := []Result{
+		&SPFResult{Value: ResultPass, From: "a@b.com"},
+		&DKIMResult{ResultBase: ResultBase{Comment: "ok"}, Value: ResultPass, Domain: "example.org"},
+		&GenericResult{Method: "X-Custom", Value: ResultFail, Params: map[string]string{"reason": "bad"}},
+	}
+	want := []string{
+		"spf=pass smtp.mailfrom=a@b.com",
+		"dkim=pass (ok) header.d=example.org",
+		"x-custom=fail reason=bad",
+	}
+	for i, r := range results {
+		if s := fmt.Sprintf("%v", r); s != want[i] {
+			t.Errorf("fmt.Sprintf(%%v) = %q, want %q", s, want[i])
+		}
+	}
+}
+
+func TestFormatWithOptions(t *testing.T) {
+	results := []Result{
+		&SPFResult{Value: ResultPass, From: "sender@example.net"},
+		&DKIMResult{Value: ResultPass, Domain: "example.net"},
+		&DMARCResult{Value: ResultPass, From: "example.net"},
+	}
+
+	tests := []struct {
+		opts *FormatOptions
+		want string
+	}{
+		{
+			opts: nil,
+			want: "mx.example.com;\r\n" +
+				"\tspf=pass smtp.mailfrom=sender@example.net; dkim=pass header.d=example.net;\r\n" +
+				"\tdmarc=pass header.from=example.net",
+		},
+		{
+			opts: &FormatOptions{Fold: false},
+			want: "mx.example.com; spf=pass smtp.mailfrom=sender@example.net;" +
+				" dkim=pass header.d=example.net; dmarc=pass header.from=example.net",
+		},
+		{
+			opts: &FormatOptions{Fold: true, MaxLineLength: 40},
+			want: "mx.example.com;\r\n" +
+				"\tspf=pass smtp.mailfrom=sender@example.net;\r\n" +
+				"\tdkim=pass header.d=example.net;\r\n" +
+				"\tdmarc=pass header.from=example.net",
+		},
+		{
+			opts: &FormatOptions{Fold: true, FieldName: "X-AR"},
+			want: "mx.example.com; spf=pass smtp.mailfrom=sender@example.net;\r\n" +
+				"\tdkim=pass header.d=example.net; dmarc=pass header.from=example.net",
+		},
+		{
+			opts: &FormatOptions{Fold: true, MaxLineLength: 200},
+			want: "mx.example.com; spf=pass smtp.mailfrom=sender@example.net;" +
+				" dkim=pass header.d=example.net; dmarc=pass header.from=example.net",
+		},
+		{
+			opts: &FormatOptions{Fold: true, Instance: 2},
+			want: "i=2; mx.example.com;\r\n" +
+				"\tspf=pass smtp.mailfrom=sender@example.net; dkim=pass header.d=example.net;\r\n" +
+				"\tdmarc=pass header.from=example.net",
+		},
+	}
+	for _, test := range tests {
+		if s := FormatWithOptions("mx.example.com", results, test.opts); s != test.want {
+			t.Errorf("FormatWithOptions(%+v) = \n%q\n want \n%q", test.opts, s, test.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	newResults := []Result{&DMARCResult{Value: ResultPass, From: "example.net"}}
+
+	tests := []struct {
+		existing string
+		want     string
+	}{
+		{
+			existing: "mx.example.com; spf=pass smtp.mailfrom=example.net",
+			want: "mx.example.com; spf=pass smtp.mailfrom=example.net;\r\n" +
+				"\tdmarc=pass header.from=example.net",
+		},
+		{
+			existing: "MX.example.com; none",
+			want:     "MX.example.com; dmarc=pass header.from=example.net",
+		},
+		{
+			existing: "i=2; mx.example.com; arc=pass",
+			want:     "i=2; mx.example.com; arc=pass;\r\n\tdmarc=pass header.from=example.net",
+		},
+		{
+			existing: "relay.example.org; spf=pass smtp.mailfrom=example.net",
+			want:     "mx.example.com; dmarc=pass header.from=example.net",
+		},
+		{
+			existing: "",
+			want:     "mx.example.com; dmarc=pass header.from=example.net",
+		},
+		{
+			existing: "mx.example.com 2; spf=pass",
+			want:     "mx.example.com; dmarc=pass header.from=example.net",
+		},
+	}
+	for _, test := range tests {
+		if s := Merge(test.existing, "mx.example.com", newResults); s != test.want {
+			t.Errorf("Merge(%q) = %q, want %q", test.existing, s, test.want)
+		}
+	}
+}
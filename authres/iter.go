@@ -0,0 +1,21 @@
+//go:build go1.23
+
+package authres
+
+import "iter"
+
+// Iter returns an iterator over the results, in order of appearance. The
+// results aren't copied.
+//
+//	for r := range parsed.Iter() {
+//		// ...
+//	}
+func (p *Parsed) Iter() iter.Seq[Result] {
+	return func(yield func(Result) bool) {
+		for _, r := range p.Results {
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
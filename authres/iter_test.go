@@ -0,0 +1,31 @@
+//go:build go1.23
+
+package authres
+
+import (
+	"testing"
+)
+
+func TestParsed_Iter(t *testing.T) {
+	p := Parse("example.com; dkim=pass; spf=fail; dmarc=pass")
+
+	var l []Result
+	for r := range p.Iter() {
+		l = append(l, r)
+		if len(l) == 2 {
+			break
+		}
+	}
+	if len(l) != 2 || l[0] != p.Results[0] || l[1] != p.Results[1] {
+		t.Errorf("Iter() yielded %v, want the first 2 results of %v", l, p.Results)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		for r := range p.Iter() {
+			_ = r
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Iter() allocated %v times, want 0", allocs)
+	}
+}
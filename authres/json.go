@@ -0,0 +1,127 @@
+package authres
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// jsonParsed is the JSON representation of Parsed.
+type jsonParsed struct {
+	Identifier string       `json:"identifier"`
+	Instance   int          `json:"instance"`
+	Results    []jsonResult `json:"results"`
+}
+
+// jsonResult is the JSON representation of a Result. Typed results and
+// GenericResult have the same representation.
+type jsonResult struct {
+	Method  string            `json:"method"`
+	Version int               `json:"version,omitempty"`
+	Value   ResultValue       `json:"value"`
+	Comment string            `json:"comment,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. Parsed is encoded as an object with
+// the identifier, the instance and the results. Each result is encoded as an
+// object with its method, value and params, whatever its type:
+//
+//	{
+//		"identifier": "example.org",
+//		"instance": 0,
+//		"results": [
+//			{"method": "spf", "value": "pass", "params": {"smtp.mailfrom": "example.net"}}
+//		]
+//	}
+func (p *Parsed) MarshalJSON() ([]byte, error) {
+	jp := jsonParsed{
+		Identifier: p.Identifier,
+		Instance:   p.Instance,
+		Results:    make([]jsonResult, 0, len(p.Results)),
+	}
+	for _, r := range p.Results {
+		value, params := resultParams(r)
+		jr := jsonResult{
+			Method:  resultMethod(r),
+			Version: r.base().formatVersion(),
+			Value:   value,
+			Comment: r.base().Comment,
+		}
+		for k, v := range params {
+			if v == "" {
+				continue
+			}
+			if jr.Params == nil {
+				jr.Params = make(map[string]string)
+			}
+			jr.Params[k] = v
+		}
+		jp.Results = append(jp.Results, jr)
+	}
+	return json.Marshal(&jp)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Results are decoded to their
+// concrete type based on their method, as done by Parse. If the JSON value is
+// malformed, an error is returned and p is left unchanged.
+//
+// Params are an object, so the order of the properties isn't preserved: they
+// are decoded sorted by key. In particular, HeaderKey and HeaderValue of
+// SenderIDResult contain the first header property in this order, which may
+// not be the first one of the original header field.
+func (p *Parsed) UnmarshalJSON(b []byte) error {
+	var jp jsonParsed
+	if err := json.Unmarshal(b, &jp); err != nil {
+		return err
+	}
+
+	parsed := Parsed{
+		Identifier: jp.Identifier,
+		Version:    1,
+		Instance:   jp.Instance,
+	}
+	for _, jr := range jp.Results {
+		r, err := jr.result()
+		if err != nil {
+			return err
+		}
+		r.base().ARCInstance = jp.Instance
+		parsed.Results = append(parsed.Results, r)
+	}
+	*p = parsed
+	return nil
+}
+
+func (jr *jsonResult) result() (Result, error) {
+	method := strings.ToLower(jr.Method)
+	if method == "" || jr.Version < 0 {
+		return nil, ErrMalformedMethod
+	}
+
+	keys := make([]string, 0, len(jr.Params))
+	for k := range jr.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := make(map[string]string, len(jr.Params))
+	var props []Property
+	for _, k := range keys {
+		v := jr.Params[k]
+		params[strings.ToLower(k)] = v
+		if prop, ok := parseProperty(k, v); ok {
+			props = append(props, prop)
+		}
+	}
+
+	r, err := newMethodResult(method, jr.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	fillResult(r, ResultValue(strings.ToLower(string(jr.Value))), params, props)
+	r.base().setVersion(jr.Version)
+	r.base().Comment = jr.Comment
+	return r, nil
+}
@@ -0,0 +1,106 @@
+package authres
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const jsonTestValue = "i=1; example.com;" +
+	" spf=pass (sender ok) smtp.mailfrom=example.net;" +
+	" dkim/1=fail reason=bad header.d=example.org header.x=y;" +
+	" x-custom=pass header.From=example.org"
+
+const jsonTestJSON = `{"identifier":"example.com","instance":1,"results":[` +
+	`{"method":"spf","value":"pass","comment":"sender ok","params":{"smtp.mailfrom":"example.net"}},` +
+	`{"method":"dkim","version":1,"value":"fail","params":{"header.d":"example.org","header.x":"y","reason":"bad"}},` +
+	`{"method":"x-custom","value":"pass","params":{"header.from":"example.org"}}]}`
+
+func TestParsed_MarshalJSON(t *testing.T) {
+	p := Parse(jsonTestValue)
+	if p.Error != nil {
+		t.Fatalf("Parse() = %v", p.Error)
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	if string(b) != jsonTestJSON {
+		t.Errorf("json.Marshal() = \n%v\n want \n%v", string(b), jsonTestJSON)
+	}
+
+	b, err = json.Marshal(&Parsed{Identifier: "example.com"})
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	if want := `{"identifier":"example.com","instance":0,"results":[]}`; string(b) != want {
+		t.Errorf("json.Marshal() = %v, want %v", string(b), want)
+	}
+}
+
+func TestParsed_UnmarshalJSON(t *testing.T) {
+	var p Parsed
+	if err := json.Unmarshal([]byte(jsonTestJSON), &p); err != nil {
+		t.Fatalf("json.Unmarshal() = %v", err)
+	}
+
+	want := Parse(jsonTestValue)
+	if !p.Equal(want) {
+		t.Errorf("Expected decoded value to be equal to the parsed one, got diff:\n%v", Diff(&p, want))
+	}
+	if _, ok := First[*SPFResult](&p); !ok {
+		t.Errorf("Expected an SPF result, got %v", p.Results)
+	}
+	dkim, ok := First[*DKIMResult](&p)
+	if !ok || dkim.Version != 1 || dkim.Reason != "bad" || !reflect.DeepEqual(dkim.Extra, map[string]string{"header.x": "y"}) {
+		t.Errorf("Expected a DKIM result, got %#v", dkim)
+	}
+
+	q := Parse("example.com; none")
+	for _, s := range []string{
+		`{"results":[{"method":"dkim","version":2,"value":"pass"}]}`,
+		`{"results":[{"value":"pass"}]}`,
+		`{"results":42}`,
+	} {
+		if err := json.Unmarshal([]byte(s), q); err == nil {
+			t.Errorf("json.Unmarshal(%v): expected an error", s)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"results":[{"method":"dkim","version":2,"value":"pass"}]}`), q); !errors.Is(err, ErrUnsupportedMethodVersion) {
+		t.Errorf("Expected error %v, got %v", ErrUnsupportedMethodVersion, err)
+	}
+	if q.Identifier != "example.com" || !q.None {
+		t.Errorf("Expected json.Unmarshal to leave the value unchanged, got %+v", q)
+	}
+}
+
+func TestParsed_UnmarshalJSON_senderID(t *testing.T) {
+	p := Parse("example.com; sender-id=pass header.sender=a@example.org header.from=b.example.org")
+	if r := p.Results[0].(*SenderIDResult); r.HeaderKey != "sender" {
+		t.Fatalf("Expected HeaderKey to be %q, got %q", "sender", r.HeaderKey)
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	var q Parsed
+	if err := json.Unmarshal(b, &q); err != nil {
+		t.Fatalf("json.Unmarshal() = %v", err)
+	}
+
+	// Properties are decoded sorted by key
+	r := q.Results[0].(*SenderIDResult)
+	if r.HeaderKey != "from" || r.HeaderValue != "b.example.org" {
+		t.Errorf("Expected the first header property in key order, got %q=%q", r.HeaderKey, r.HeaderValue)
+	}
+	want := []Property{{PropertyHeader, "from", "b.example.org"}, {PropertyHeader, "sender", "a@example.org"}}
+	if !reflect.DeepEqual(r.Headers, want) {
+		t.Errorf("Expected headers %v, got %v", want, r.Headers)
+	}
+	if s, want := r.String(), p.Results[0].(*SenderIDResult).String(); s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+}
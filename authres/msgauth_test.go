@@ -21,8 +21,8 @@ var msgauthTests = []msgauthTest{
 		},
 	},
 	{
-		value: "example.com;" +
-			" spf=fail reason=bad smtp.mailfrom=example.net",
+		value: "example.com;\r\n" +
+			"\tspf=fail reason=bad smtp.mailfrom=example.net",
 		identifier: "example.com",
 		results: []Result{
 			&SPFResult{Value: ResultFail, Reason: "bad", From: "example.net"},
@@ -30,36 +30,78 @@ var msgauthTests = []msgauthTest{
 	},
 	{
 		value: "example.com;" +
-			" auth=pass smtp.auth=sender@example.com;" +
-			" spf=pass smtp.mailfrom=example.com",
+			" auth=pass smtp.auth=sender@example.com;\r\n" +
+			"\tspf=pass smtp.mailfrom=example.com",
 		identifier: "example.com",
 		results: []Result{
 			&AuthResult{Value: ResultPass, Auth: "sender@example.com"},
 			&SPFResult{Value: ResultPass, From: "example.com"},
 		},
 	},
+	{
+		value: "example.com;\r\n" +
+			"\tauth=pass smtp.auth=alice smtp.mailfrom=alice@example.com",
+		identifier: "example.com",
+		results: []Result{
+			&AuthResult{Value: ResultPass, Auth: "alice", MailFrom: "alice@example.com"},
+		},
+	},
+	{
+		value: "example.com;\r\n" +
+			"\tauth=fail reason=\"bad password\" smtp.auth=alice",
+		identifier: "example.com",
+		results: []Result{
+			&AuthResult{Value: ResultFail, Reason: "bad password", Auth: "alice"},
+		},
+	},
+	{
+		value: "example.com;\r\n" +
+			"\tiprev=pass dns.sec=yes dns.zone=arpa policy.iprev=192.0.2.1",
+		identifier: "example.com",
+		results: []Result{
+			&IPRevResult{
+				ResultBase: ResultBase{
+					Extra:  map[string]string{"dns.zone": "arpa"},
+					Policy: map[string]string{"iprev": "192.0.2.1"},
+				},
+				Value:  ResultPass,
+				IP:     "192.0.2.1",
+				DNSSEC: "yes",
+			},
+		},
+	},
 	{
 		value: "example.com;" +
 			" sender-id=pass header.from=example.com",
 		identifier: "example.com",
 		results: []Result{
-			&SenderIDResult{Value: ResultPass, HeaderKey: "from", HeaderValue: "example.com"},
+			&SenderIDResult{
+				Value:       ResultPass,
+				HeaderKey:   "from",
+				HeaderValue: "example.com",
+				Headers:     []Property{{PropertyHeader, "from", "example.com"}},
+			},
 		},
 	},
 	{
-		value: "example.com;" +
-			" sender-id=hardfail header.from=example.com;" +
-			" dkim=pass header.i=sender@example.com",
+		value: "example.com;\r\n" +
+			"\tsender-id=hardfail header.from=example.com;\r\n" +
+			"\tdkim=pass header.i=sender@example.com",
 		identifier: "example.com",
 		results: []Result{
-			&SenderIDResult{Value: ResultHardFail, HeaderKey: "from", HeaderValue: "example.com"},
+			&SenderIDResult{
+				Value:       ResultHardFail,
+				HeaderKey:   "from",
+				HeaderValue: "example.com",
+				Headers:     []Property{{PropertyHeader, "from", "example.com"}},
+			},
 			&DKIMResult{Value: ResultPass, Identifier: "sender@example.com"},
 		},
 	},
 	{
 		value: "example.com;" +
-			" auth=pass smtp.auth=sender@example.com;" +
-			" spf=hardfail smtp.mailfrom=example.com",
+			" auth=pass smtp.auth=sender@example.com;\r\n" +
+			"\tspf=hardfail smtp.mailfrom=example.com",
 		identifier: "example.com",
 		results: []Result{
 			&AuthResult{Value: ResultPass, Auth: "sender@example.com"},
@@ -67,13 +109,126 @@ var msgauthTests = []msgauthTest{
 		},
 	},
 	{
-		value: "example.com;" +
-			" dkim=pass header.i=@mail-router.example.net;" +
-			" dkim=fail header.i=@newyork.example.com",
+		value: "example.com;\r\n" +
+			"\tdkim=pass header.i=@mail-router.example.net;\r\n" +
+			"\tdkim=fail header.i=@newyork.example.com",
 		identifier: "example.com",
 		results: []Result{
 			&DKIMResult{Value: ResultPass, Identifier: "@mail-router.example.net"},
 			&DKIMResult{Value: ResultFail, Identifier: "@newyork.example.com"},
 		},
 	},
+	{
+		value: "example.com;\r\n" +
+			"\tbimi=pass header.d=example.org header.selector=default policy.authority=pass",
+		identifier: "example.com",
+		results: []Result{
+			&BIMIResult{
+				ResultBase: ResultBase{Policy: map[string]string{"authority": "pass"}},
+				Value:      ResultPass,
+				Domain:     "example.org",
+				Selector:   "default",
+				Authority:  "pass",
+			},
+		},
+	},
+	{
+		value: "example.com;\r\n" +
+			"\tdkim=pass header.a=rsa-sha256 header.b=AbCd1234 header.d=example.org header.s=selector1",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{
+				Value:     ResultPass,
+				Domain:    "example.org",
+				Selector:  "selector1",
+				Algorithm: "rsa-sha256",
+				Signature: "AbCd1234",
+			},
+		},
+	},
+	{
+		value: "example.com;\r\n" +
+			"\tdkim=pass header.d=example.org header.t=1700000000 x-foo=bar",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{
+				ResultBase: ResultBase{
+					Extra: map[string]string{"header.t": "1700000000", "x-foo": "bar"},
+				},
+				Value:  ResultPass,
+				Domain: "example.org",
+			},
+		},
+	},
+	{
+		value: "example.com;\r\n" +
+			"\tarc=pass arc.cv=pass arc.oldest-pass=1 header.i=2",
+		identifier: "example.com",
+		results: []Result{
+			&ARCResult{Value: ResultPass, Instance: 2, ChainValidation: ResultPass, OldestPass: 1},
+		},
+	},
+	{
+		value: "example.com;" +
+			" dkim/1=pass header.d=example.org;\r\n" +
+			"\tx-custom/2=fail",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{Version: 1, ExplicitVersion: true}, Value: ResultPass, Domain: "example.org"},
+			&GenericResult{ResultBase: ResultBase{Version: 2, ExplicitVersion: true}, Method: "x-custom", Value: ResultFail, Params: map[string]string{}},
+		},
+	},
+	{
+		value: "example.com;\r\n" +
+			"\tsender-id=pass header.From=example.com header.sender=list@example.com",
+		identifier: "example.com",
+		results: []Result{
+			&SenderIDResult{
+				Value:       ResultPass,
+				HeaderKey:   "From",
+				HeaderValue: "example.com",
+				Headers: []Property{
+					{PropertyHeader, "From", "example.com"},
+					{PropertyHeader, "sender", "list@example.com"},
+				},
+			},
+		},
+	},
+	{
+		value: "example.com;\r\n" +
+			"\tspf=pass (sender \\(really\\) ok) smtp.mailfrom=example.net",
+		identifier: "example.com",
+		results: []Result{
+			&SPFResult{ResultBase: ResultBase{Comment: "sender (really) ok"}, Value: ResultPass, From: "example.net"},
+		},
+	},
+	{
+		value: "example.com;\r\n" +
+			"\tdmarc=fail header.from=example.net policy.dmarc=reject",
+		identifier: "example.com",
+		results: []Result{
+			&DMARCResult{
+				ResultBase:  ResultBase{Policy: map[string]string{"dmarc": "reject"}},
+				Value:       ResultFail,
+				From:        "example.net",
+				Disposition: "reject",
+			},
+		},
+	},
+	{
+		value: "example.com;\r\n" +
+			"\tdmarc=fail reason=\"dis=QUARANTINE\" header.from=example.net",
+		identifier: "example.com",
+		results: []Result{
+			&DMARCResult{Value: ResultFail, Reason: "dis=QUARANTINE", From: "example.net", Disposition: "quarantine"},
+		},
+	},
+	{
+		value: "example.com;" +
+			" dkim-adsp=discard header.from=example.net",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMADSPResult{Value: "discard", From: "example.net"},
+		},
+	},
 }
@@ -1,11 +1,16 @@
 package authres
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
-
 )
 
 // ResultValue is an authentication result value, as defined in RFC 5451 section
@@ -14,50 +19,171 @@ type ResultValue string
 
 const (
 	ResultNone      ResultValue = "none"
-	ResultPass                  = "pass"
-	ResultFail                  = "fail"
-	ResultPolicy                = "policy"
-	ResultNeutral               = "neutral"
-	ResultTempError             = "temperror"
-	ResultPermError             = "permerror"
-	ResultHardFail              = "hardfail"
-	ResultSoftFail              = "softfail"
+	ResultPass      ResultValue = "pass"
+	ResultFail      ResultValue = "fail"
+	ResultPolicy    ResultValue = "policy"
+	ResultNeutral   ResultValue = "neutral"
+	ResultTempError ResultValue = "temperror"
+	ResultPermError ResultValue = "permerror"
+	ResultHardFail  ResultValue = "hardfail"
+	ResultSoftFail  ResultValue = "softfail"
 )
 
+// Severity returns the severity of the result value, so that the worst of
+// several results can be picked by taking the highest severity. Values are
+// ordered as follows, from the least to the most severe:
+//
+//	pass < none < neutral < policy < softfail < fail = hardfail < temperror < permerror
+//
+// Unknown values have the severity of neutral. Values are case-insensitive.
+func (v ResultValue) Severity() int {
+	switch ResultValue(strings.ToLower(string(v))) {
+	case ResultPass:
+		return 0
+	case ResultNone:
+		return 1
+	case ResultPolicy:
+		return 3
+	case ResultSoftFail:
+		return 4
+	case ResultFail, ResultHardFail:
+		return 5
+	case ResultTempError:
+		return 6
+	case ResultPermError:
+		return 7
+	default:
+		return 2 // neutral
+	}
+}
+
 type Parsed struct {
 	Identifier string
-	Instance int
-	Results []Result
-	Error error
+	// Version is the version of the header field. It defaults to 1 if the
+	// header field doesn't state it explicitly, see ExplicitVersion. Only
+	// version 1 is supported: other versions are recorded here, and Error is
+	// set to ErrUnsupportedVersion.
+	Version int
+	// ExplicitVersion is true if the version is stated in the header field,
+	// e.g. "example.org 1; none".
+	ExplicitVersion bool
+	Instance        int
+	Results         []Result
+	// None is true if the header field explicitly states that no message
+	// authentication was performed, e.g. "example.org; none".
+	None bool
+	// Comments contains the text of the comments found in the header field,
+	// in order of appearance.
+	Comments []string
+	// Unknown contains the methods of the results dropped by ParseKnownOnly,
+	// in order of appearance.
+	Unknown []string
+	// Warnings describes the problems which didn't prevent the header field
+	// from being parsed, e.g. malformed or duplicate properties, in order of
+	// appearance.
+	Warnings []string
+	Error    error
 }
 
 // Result is an authentication result.
 type Result interface {
 	parse(value ResultValue, params map[string]string)
 	format() (value ResultValue, params map[string]string)
+	base() *ResultBase
+
+	// Clone returns a deep copy of the result.
+	Clone() Result
+	// Reasons returns the reason of the result, followed by the values of
+	// the other reason-like params, e.g. "x-reason".
+	Reasons() []string
+}
+
+// ResultBase contains the fields shared by all result types.
+type ResultBase struct {
+	// Version is the version of the authentication method. It defaults to 1
+	// if the result doesn't state it explicitly, see ExplicitVersion.
+	Version int
+	// ExplicitVersion is true if the version is stated in the result, e.g.
+	// "dkim/1=pass". The version is only formatted if it's explicit or other
+	// than 1.
+	ExplicitVersion bool
+	// Comment is the comment following the result value, if any.
+	Comment string
+	// Raw is the original text of the result in the parsed header field,
+	// including comments, whitespace and line breaks. It's ignored by Format.
+	Raw string
+	// Extra contains the properties which aren't mapped to a field of the
+	// result. It's unused by GenericResult, which stores all properties in
+	// Params.
+	Extra map[string]string
+	// Policy contains all the policy properties, whether they're mapped to a
+	// field of the result or not, e.g. "dmarc" for "policy.dmarc". Keys are
+	// lowercase. When formatting, fields and Extra take precedence.
+	Policy map[string]string
+	// ARCInstance is the instance of the ARC-Authentication-Results header
+	// field the result was parsed from, or zero for an Authentication-Results
+	// header field. It's ignored by Format.
+	ARCInstance int
+}
+
+func (b *ResultBase) base() *ResultBase {
+	return b
+}
+
+// setVersion sets the version of the result to v, or to the default version if
+// v is zero.
+func (b *ResultBase) setVersion(v int) {
+	b.Version, b.ExplicitVersion = v, v > 0
+	if v == 0 {
+		b.Version = 1
+	}
+}
+
+// formatVersion returns the version of the result to format, or zero if it
+// shouldn't be formatted.
+func (b *ResultBase) formatVersion() int {
+	if b.Version > 0 && (b.ExplicitVersion || b.Version != 1) {
+		return b.Version
+	}
+	return 0
 }
 
 type AuthResult struct {
-	Value  ResultValue
-	Reason string
-	Auth   string
+	ResultBase
+
+	Value    ResultValue
+	Reason   string
+	Auth     string
+	MailFrom string
 }
 
 func (r *AuthResult) parse(value ResultValue, params map[string]string) {
 	r.Value = value
 	r.Reason = params["reason"]
 	r.Auth = params["smtp.auth"]
+	r.MailFrom = params["smtp.mailfrom"]
 }
 
 func (r *AuthResult) format() (ResultValue, map[string]string) {
-	return r.Value, map[string]string{"smtp.auth": r.Auth}
+	return r.Value, map[string]string{
+		"reason":        r.Reason,
+		"smtp.auth":     r.Auth,
+		"smtp.mailfrom": r.MailFrom,
+	}
 }
 
 type DKIMResult struct {
+	ResultBase
+
 	Value      ResultValue
 	Reason     string
 	Domain     string
 	Identifier string
+	Selector   string
+	Algorithm  string
+	// Signature contains the first characters of the signature, as
+	// specified in RFC 6008.
+	Signature string
 }
 
 func (r *DKIMResult) parse(value ResultValue, params map[string]string) {
@@ -65,6 +191,9 @@ func (r *DKIMResult) parse(value ResultValue, params map[string]string) {
 	r.Reason = params["reason"]
 	r.Domain = params["header.d"]
 	r.Identifier = params["header.i"]
+	r.Selector = params["header.s"]
+	r.Algorithm = params["header.a"]
+	r.Signature = params["header.b"]
 }
 
 func (r *DKIMResult) format() (ResultValue, map[string]string) {
@@ -72,10 +201,47 @@ func (r *DKIMResult) format() (ResultValue, map[string]string) {
 		"reason":   r.Reason,
 		"header.d": r.Domain,
 		"header.i": r.Identifier,
+		"header.s": r.Selector,
+		"header.a": r.Algorithm,
+		"header.b": r.Signature,
 	}
 }
 
+// Authenticated reports whether the DKIM signature was verified.
+func (r *DKIMResult) Authenticated() bool {
+	return r.Value == ResultPass
+}
+
+// Rejectable reports whether the DKIM signature failed verification.
+func (r *DKIMResult) Rejectable() bool {
+	return r.Value == ResultFail
+}
+
+// SignaturePrefix decodes the header.b property, which contains the first
+// characters of the base64-encoded DKIM signature. It can be used to find the
+// DKIM-Signature header field the result refers to. nil is returned if the
+// property is missing.
+func (r *DKIMResult) SignaturePrefix() ([]byte, error) {
+	s := strings.TrimRight(r.Signature, "=")
+	if s == "" {
+		return nil, nil
+	}
+	// The signature may be truncated in the middle of a base64 quantum
+	if len(s)%4 == 1 {
+		s = s[:len(s)-1]
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// Timestamp returns the signature timestamp from the header.t or x-timestamp
+// property, if any. The zero time is returned if it's missing or malformed.
+func (r *DKIMResult) Timestamp() time.Time {
+	return extraTimestamp(r.Extra)
+}
+
 type DomainKeysResult struct {
+	ResultBase
+
 	Value  ResultValue
 	Reason string
 	Domain string
@@ -101,56 +267,123 @@ func (r *DomainKeysResult) format() (ResultValue, map[string]string) {
 }
 
 type IPRevResult struct {
+	ResultBase
+
 	Value  ResultValue
 	Reason string
 	IP     string
+	// DNSSEC is the DNSSEC status of the reverse DNS lookup, from the dns.sec
+	// property. Other DNS properties, e.g. dns.zone, are available in Extra.
+	DNSSEC string
 }
 
 func (r *IPRevResult) parse(value ResultValue, params map[string]string) {
 	r.Value = value
 	r.Reason = params["reason"]
 	r.IP = params["policy.iprev"]
+	r.DNSSEC = params["dns.sec"]
 }
 
 func (r *IPRevResult) format() (ResultValue, map[string]string) {
 	return r.Value, map[string]string{
 		"reason":       r.Reason,
 		"policy.iprev": r.IP,
+		"dns.sec":      r.DNSSEC,
+	}
+}
+
+// NormalizedIP parses the policy.iprev property. Square brackets and the
+// "IPv6:" prefix of address literals are accepted, e.g. "[IPv6:2001:db8::1]".
+// IPv6 zones are stripped, e.g. "fe80::1%eth0". IPv4 addresses are returned in
+// their 4-byte form. nil is returned if the property isn't a valid IP address.
+func (r *IPRevResult) NormalizedIP() net.IP {
+	s := strings.TrimSpace(r.IP)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	if len(s) > 5 && strings.EqualFold(s[:5], "IPv6:") {
+		s = s[5:]
+	}
+	if i := strings.IndexByte(s, '%'); i >= 0 && strings.Contains(s, ":") {
+		s = s[:i]
 	}
+
+	ip := net.ParseIP(s)
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// IPMatches reports whether the policy.iprev property is ip, e.g. the address
+// of the SMTP client. Addresses are normalized as in NormalizedIP.
+func (r *IPRevResult) IPMatches(ip net.IP) bool {
+	normalized := r.NormalizedIP()
+	return normalized != nil && normalized.Equal(ip)
 }
 
 type SenderIDResult struct {
-	Value       ResultValue
-	Reason      string
+	ResultBase
+
+	Value  ResultValue
+	Reason string
+	// HeaderKey and HeaderValue contain the first header property, e.g.
+	// "from" and "example.org" for "header.from=example.org".
 	HeaderKey   string
 	HeaderValue string
+	// Headers contains all the header properties, in order. When formatting,
+	// HeaderKey and HeaderValue replace its first element.
+	Headers []Property
 }
 
 func (r *SenderIDResult) parse(value ResultValue, params map[string]string) {
 	r.Value = value
 	r.Reason = params["reason"]
+}
 
-	for k, v := range params {
-		if strings.HasPrefix(k, "header.") {
-			r.HeaderKey = strings.TrimPrefix(k, "header.")
-			r.HeaderValue = v
-			break
+func (r *SenderIDResult) parseProperties(props []Property) {
+	for _, prop := range props {
+		if prop.Type != PropertyHeader {
+			continue
+		}
+		if r.Headers == nil {
+			r.HeaderKey = prop.Name
+			r.HeaderValue = prop.Value
 		}
+		r.Headers = append(r.Headers, prop)
 	}
 }
 
 func (r *SenderIDResult) format() (value ResultValue, params map[string]string) {
-	return r.Value, map[string]string{
-		"reason":                                 r.Reason,
-		"header." + strings.ToLower(r.HeaderKey): r.HeaderValue,
+	params = map[string]string{"reason": r.Reason}
+	headers := r.Headers
+	if r.HeaderKey != "" {
+		// HeaderKey and HeaderValue may have been modified
+		if len(headers) > 0 {
+			headers = headers[1:]
+		}
+		params["header."+r.HeaderKey] = r.HeaderValue
 	}
+	for _, prop := range headers {
+		if _, ok := params["header."+prop.Name]; !ok {
+			params["header."+prop.Name] = prop.Value
+		}
+	}
+	return r.Value, params
 }
 
 type SPFResult struct {
+	ResultBase
+
 	Value  ResultValue
 	Reason string
 	From   string
-	Helo   string
+	// Helo is the smtp.helo property, or the smtp.ehlo property if the
+	// former is missing.
+	Helo string
+	// Ehlo is true if Helo was read from the smtp.ehlo property. Helo is
+	// formatted as smtp.ehlo in this case.
+	Ehlo bool
 }
 
 func (r *SPFResult) parse(value ResultValue, params map[string]string) {
@@ -158,39 +391,514 @@ func (r *SPFResult) parse(value ResultValue, params map[string]string) {
 	r.Reason = params["reason"]
 	r.From = params["smtp.mailfrom"]
 	r.Helo = params["smtp.helo"]
+	if r.Helo == "" && params["smtp.ehlo"] != "" {
+		r.Helo = params["smtp.ehlo"]
+		r.Ehlo = true
+	}
 }
 
 func (r *SPFResult) format() (ResultValue, map[string]string) {
-	return r.Value, map[string]string{
+	params := map[string]string{
 		"reason":        r.Reason,
 		"smtp.mailfrom": r.From,
-		"smtp.helo":     r.Helo,
 	}
+	if r.Ehlo {
+		params["smtp.ehlo"] = r.Helo
+	} else {
+		params["smtp.helo"] = r.Helo
+	}
+	return r.Value, params
+}
+
+// Authenticated reports whether the client is authorized to send mail for the
+// domain.
+func (r *SPFResult) Authenticated() bool {
+	return r.Value == ResultPass
+}
+
+// Rejectable reports whether the client is explicitly not authorized to send
+// mail for the domain, i.e. the result is fail or hardfail.
+func (r *SPFResult) Rejectable() bool {
+	return r.Value == ResultFail || r.Value == ResultHardFail
+}
+
+// MailFromDomain returns the lowercase domain of the envelope sender. The
+// smtp.mailfrom property may either contain a domain or a full address, with or
+// without angle brackets. An empty string is returned for the null sender "<>".
+func (r *SPFResult) MailFromDomain() string {
+	_, domain := splitMailFrom(r.From)
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+// MailFromAddress returns the envelope sender without angle brackets, e.g.
+// "john@example.org" for "<john@example.org>". Source routes are removed. An
+// empty string is returned for the null sender "<>". Unlike MailFromDomain, the
+// case of the address is preserved.
+func (r *SPFResult) MailFromAddress() string {
+	local, domain := splitMailFrom(r.From)
+	if local == "" {
+		return domain
+	}
+	return local + "@" + domain
+}
+
+// MailFromLocalPart returns the local part of the envelope sender. Quoted local
+// parts are returned with their quotes, e.g. "\"john@home\"". An empty string is
+// returned if the smtp.mailfrom property only contains a domain, or for the
+// null sender "<>".
+func (r *SPFResult) MailFromLocalPart() string {
+	local, _ := splitMailFrom(r.From)
+	return local
+}
+
+// HeloIsValid reports whether the smtp.helo property is a syntactically valid
+// domain name or address literal, as defined in RFC 5321 section 4.1.2, e.g.
+// "mail.example.org" or "[192.0.2.1]".
+func (r *SPFResult) HeloIsValid() bool {
+	if isAddressLiteral(r.Helo) {
+		s := r.Helo[1 : len(r.Helo)-1]
+		if len(s) > 5 && strings.EqualFold(s[:5], "IPv6:") {
+			ip := net.ParseIP(s[5:])
+			return ip != nil && ip.To4() == nil
+		}
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	}
+	return isDomain(r.Helo)
+}
+
+// isDomain reports whether s is a syntactically valid domain name, as defined
+// in RFC 5321 section 4.1.2.
+func isDomain(s string) bool {
+	if s == "" || len(s) > 255 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			ch := label[i]
+			if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitMailFrom splits an envelope sender into its local part and domain, as
+// defined in RFC 5321 section 4.1.2. The local part may be a quoted string
+// containing "@", e.g. "\"john@home\"@example.org". Source routes are ignored.
+func splitMailFrom(s string) (local, domain string) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "<"), ">")
+	if strings.HasPrefix(s, "@") {
+		// Source route, e.g. "@relay.example.org:john@example.org"
+		if i := strings.IndexByte(s, ':'); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	i := indexAddressAt(s)
+	if i < 0 {
+		return "", s
+	}
+	return s[:i], s[i+1:]
+}
+
+// indexAddressAt returns the index of the "@" separating the local part and
+// the domain of an address, skipping quoted strings, or -1 if there is none.
+func indexAddressAt(s string) int {
+	at := -1
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '@':
+			if !quoted {
+				at = i
+			}
+		}
+	}
+	return at
+}
+
+// isQuotedString reports whether s is a quoted string, as defined in RFC 5321
+// section 4.1.2.
+func isQuotedString(s string) bool {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return false
+	}
+	for i := 1; i < len(s)-1; i++ {
+		switch ch := s[i]; {
+		case ch == '\\':
+			i++
+			if i == len(s)-1 {
+				return false
+			}
+		case ch == '"' || ch < ' ' || ch == 0x7f:
+			return false
+		}
+	}
+	return true
 }
 
 type DMARCResult struct {
+	ResultBase
+
 	Value  ResultValue
 	Reason string
 	From   string
+	// Disposition is the policy applied to the message: "none",
+	// "quarantine" or "reject". It's read from the policy.dmarc property, or
+	// from the reason, e.g. "dis=reject". Values read from the reason aren't
+	// formatted again as a property.
+	Disposition string
+	// Alignment is the identifier alignment reported by the receiver in
+	// the "policy.alignment" property, e.g. "pass", "relaxed" or the aligned
+	// domain.
+	Alignment string
+	// Override is the reason why the domain owner's policy wasn't applied,
+	// e.g. "forwarded" or "sampled_out", as defined in RFC 7489 appendix C.
+	// It's read from the policy.override property, or from the reason.
+	Override string
 }
 
 func (r *DMARCResult) parse(value ResultValue, params map[string]string) {
 	r.Value = value
 	r.Reason = params["reason"]
 	r.From = params["header.from"]
+	r.Disposition = strings.ToLower(params["policy.dmarc"])
+	if r.Disposition == "" {
+		r.Disposition = dispositionFromReason(r.Reason)
+	}
+	r.Alignment = strings.ToLower(params["policy.alignment"])
+	r.Override = strings.ToLower(params["policy.override"])
+	if r.Override == "" {
+		r.Override = overrideFromReason(r.Reason)
+	}
 }
 
 func (r *DMARCResult) format() (ResultValue, map[string]string) {
+	// Omit the properties derived from the reason when parsing, i.e. missing
+	// from Policy
+	disposition := r.Disposition
+	if _, ok := r.Policy["dmarc"]; !ok && disposition == dispositionFromReason(r.Reason) {
+		disposition = ""
+	}
+	override := r.Override
+	if _, ok := r.Policy["override"]; !ok && override == overrideFromReason(r.Reason) {
+		override = ""
+	}
+
+	return r.Value, map[string]string{
+		"reason":           r.Reason,
+		"header.from":      r.From,
+		"policy.dmarc":     disposition,
+		"policy.alignment": r.Alignment,
+		"policy.override":  override,
+	}
+}
+
+// IsAligned reports whether the receiver reported the RFC5322.From domain as
+// aligned. It returns false if Alignment is empty, or if it's an alignment mode
+// such as "relaxed" or "strict" rather than a result. When Alignment is a
+// domain, it must be the From domain or one of its parents.
+func (r *DMARCResult) IsAligned() bool {
+	switch r.Alignment {
+	case "":
+		return false
+	case "pass", "yes", "aligned":
+		return true
+	}
+	if !strings.Contains(r.Alignment, ".") {
+		return false
+	}
+	from := r.FromDomain()
+	return from == r.Alignment || strings.HasSuffix(from, "."+r.Alignment)
+}
+
+// Authenticated reports whether the message passed the DMARC evaluation.
+func (r *DMARCResult) Authenticated() bool {
+	return r.Value == ResultPass
+}
+
+// Rejectable reports whether the message failed the DMARC evaluation. The
+// domain owner's policy, available in Disposition, may still request the
+// message to be delivered.
+func (r *DMARCResult) Rejectable() bool {
+	return r.Value == ResultFail
+}
+
+// dispositionFromReason extracts the DMARC disposition from a free-form reason,
+// e.g. "dis=quarantine" or "action=reject".
+func dispositionFromReason(s string) string {
+	for _, f := range strings.Fields(strings.ToLower(s)) {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok || (k != "dis" && k != "disposition" && k != "action") {
+			continue
+		}
+		switch v = strings.TrimRight(v, ",;"); v {
+		case "none", "quarantine", "reject":
+			return v
+		}
+	}
+	return ""
+}
+
+// overrideFromReason extracts the DMARC policy override from a free-form
+// reason, e.g. "override=mailing_list". A bare override type is only accepted
+// if it's the whole reason, e.g. "forwarded" but not "not forwarded".
+func overrideFromReason(s string) string {
+	fields := strings.Fields(strings.ToLower(s))
+	for _, f := range fields {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok && len(fields) == 1 {
+			v = f
+		} else if k != "override" && k != "policy.override" {
+			continue
+		}
+		switch v = strings.TrimRight(v, ",;"); v {
+		case "forwarded", "sampled_out", "trusted_forwarder", "mailing_list", "local_policy":
+			return v
+		}
+	}
+	return ""
+}
+
+// FromDomain returns the lowercase domain of the From header field. The
+// header.from property may either contain a domain or a full address. An empty
+// string is returned if it can't be parsed.
+//
+// The organizational domain isn't computed, since this requires the Public
+// Suffix List.
+func (r *DMARCResult) FromDomain() string {
+	s := strings.Join(strings.Fields(r.From), " ")
+	if strings.ContainsAny(s, "@<") {
+		addr, err := mail.ParseAddress(s)
+		if err != nil {
+			return ""
+		}
+		s = addr.Address[strings.LastIndexByte(addr.Address, '@')+1:]
+	}
+
+	s = strings.ToLower(strings.TrimSuffix(s, "."))
+	if strings.ContainsAny(s, " \"()[]") {
+		return ""
+	}
+	return s
+}
+
+// DKIMADSPResult is a DKIM Author Domain Signing Practices result, as defined
+// in RFC 5617. ADSP has been declared historic, but some receivers still
+// report it.
+type DKIMADSPResult struct {
+	ResultBase
+
+	Value  ResultValue
+	Reason string
+	From   string
+}
+
+func (r *DKIMADSPResult) parse(value ResultValue, params map[string]string) {
+	r.Value = value
+	r.Reason = params["reason"]
+	r.From = params["header.from"]
+}
+
+func (r *DKIMADSPResult) format() (ResultValue, map[string]string) {
 	return r.Value, map[string]string{
 		"reason":      r.Reason,
 		"header.from": r.From,
 	}
 }
 
+type BIMIResult struct {
+	ResultBase
+
+	Value     ResultValue
+	Reason    string
+	Domain    string
+	Selector  string
+	Authority string
+}
+
+func (r *BIMIResult) parse(value ResultValue, params map[string]string) {
+	r.Value = value
+	r.Reason = params["reason"]
+	r.Domain = params["header.d"]
+	r.Selector = params["header.selector"]
+	r.Authority = params["policy.authority"]
+}
+
+func (r *BIMIResult) format() (ResultValue, map[string]string) {
+	return r.Value, map[string]string{
+		"reason":           r.Reason,
+		"header.d":         r.Domain,
+		"header.selector":  r.Selector,
+		"policy.authority": r.Authority,
+	}
+}
+
+type ARCResult struct {
+	ResultBase
+
+	Value  ResultValue
+	Reason string
+	// Instance is the ARC instance the result refers to, or zero if unknown.
+	Instance        int
+	ChainValidation ResultValue
+	// OldestPass is the lowest ARC instance which passed validation, or zero
+	// if unknown.
+	OldestPass int
+	// Chain contains the domains listed in the arc.chain property, in order.
+	Chain []string
+}
+
+func (r *ARCResult) parse(value ResultValue, params map[string]string) {
+	r.Value = value
+	r.Reason = params["reason"]
+	r.Instance = parseInstance(params["header.i"])
+	if r.Instance == 0 {
+		r.Instance = parseInstance(params["arc.i"])
+	}
+	r.ChainValidation = ResultValue(strings.ToLower(params["arc.cv"]))
+	r.OldestPass = parseInstance(params["arc.oldest-pass"])
+	r.Chain = parseARCChain(params["arc.chain"])
+}
+
+// parseARCChain parses a comma-separated list of domains. Empty items are
+// ignored.
+func parseARCChain(s string) []string {
+	var l []string
+	for _, domain := range strings.Split(s, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			l = append(l, domain)
+		}
+	}
+	return l
+}
+
+func (r *ARCResult) format() (ResultValue, map[string]string) {
+	return r.Value, map[string]string{
+		"reason":   r.Reason,
+		"header.i": formatInstance(r.Instance),
+		// arc.i is parsed as an alias of header.i
+		"arc.i":           "",
+		"arc.cv":          string(r.ChainValidation),
+		"arc.oldest-pass": formatInstance(r.OldestPass),
+		"arc.chain":       strings.Join(r.Chain, ","),
+	}
+}
+
+// Timestamp returns the signature timestamp from the header.t or x-timestamp
+// property, if any. The zero time is returned if it's missing or malformed.
+func (r *ARCResult) Timestamp() time.Time {
+	return extraTimestamp(r.Extra)
+}
+
+// extraTimestamp parses the timestamp in the header.t or x-timestamp extra
+// param, with any property type. Both Unix timestamps and RFC 3339 dates are
+// accepted.
+func extraTimestamp(extra map[string]string) time.Time {
+	v, ok := extra["header.t"]
+	if !ok {
+		v, ok = extra["x-timestamp"]
+	}
+	if !ok {
+		for k, ev := range extra {
+			if strings.HasSuffix(k, ".x-timestamp") {
+				v, ok = ev, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return time.Time{}
+	}
+
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0)
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// parseInstance parses an ARC instance number. It returns zero if s isn't a
+// valid instance.
+func parseInstance(s string) int {
+	i, err := strconv.Atoi(s)
+	// Instance tag values can range from 1-50 (inclusive).
+	if err != nil || i < 1 || i > 50 {
+		return 0
+	}
+	return i
+}
+
+func formatInstance(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
+
+// PropertyType is the type of a result property, as defined in RFC 8601
+// section 2.3.
+type PropertyType string
+
+const (
+	PropertySMTP   PropertyType = "smtp"
+	PropertyHeader PropertyType = "header"
+	PropertyBody   PropertyType = "body"
+	PropertyPolicy PropertyType = "policy"
+	PropertyDNS    PropertyType = "dns"
+)
+
+// Property is a result property, e.g. "header.d=example.org".
+type Property struct {
+	Type  PropertyType
+	Name  string
+	Value string
+}
+
+// parseProperty parses a property key and value. It returns false if k isn't
+// a property key, e.g. for "reason".
+func parseProperty(k, v string) (Property, bool) {
+	ptype, name, ok := strings.Cut(k, ".")
+	if !ok {
+		return Property{}, false
+	}
+	return Property{
+		Type:  PropertyType(strings.ToLower(strings.TrimSpace(ptype))),
+		Name:  strings.TrimSpace(name),
+		Value: v,
+	}, true
+}
+
+// propertiesParser is implemented by results which need the properties in
+// order of appearance, with their names in their original case.
+type propertiesParser interface {
+	parseProperties(props []Property)
+}
+
 type GenericResult struct {
+	ResultBase
+
 	Method string
 	Value  ResultValue
 	Params map[string]string
+	// Properties contains the parsed properties, in order of appearance and
+	// with their names in their original case. It's populated by Parse, but
+	// isn't used by Format.
+	Properties []Property
 }
 
 func (r *GenericResult) parse(value ResultValue, params map[string]string) {
@@ -198,10 +906,49 @@ func (r *GenericResult) parse(value ResultValue, params map[string]string) {
 	r.Params = params
 }
 
+func (r *GenericResult) parseProperties(props []Property) {
+	r.Properties = props
+}
+
 func (r *GenericResult) format() (ResultValue, map[string]string) {
 	return r.Value, r.Params
 }
 
+func (r *GenericResult) generic() *GenericResult {
+	return r
+}
+
+// PropertiesByType returns the properties of Params grouped by type, e.g.
+// "header.d=example.org" is returned as m[PropertyHeader]["d"]. Property types
+// are lowercase. Params which aren't properties, e.g. "reason", are omitted.
+func (r *GenericResult) PropertiesByType() map[PropertyType]map[string]string {
+	m := make(map[PropertyType]map[string]string)
+	for k, v := range r.Params {
+		prop, ok := parseProperty(k, v)
+		if !ok {
+			continue
+		}
+		if m[prop.Type] == nil {
+			m[prop.Type] = make(map[string]string)
+		}
+		m[prop.Type][prop.Name] = prop.Value
+	}
+	return m
+}
+
+// genericResult is implemented by GenericResult and by the custom result types
+// embedding it.
+type genericResult interface {
+	Result
+	generic() *GenericResult
+}
+
+// IsExperimental reports whether the method is an experimental one, i.e.
+// starts with "x-", as defined in RFC 8601 section 2.7.7.
+func (r *GenericResult) IsExperimental() bool {
+	return len(r.Method) >= 2 && strings.EqualFold(r.Method[:2], "x-")
+}
+
 type newResultFunc func() Result
 
 var results = map[string]newResultFunc{
@@ -226,111 +973,915 @@ var results = map[string]newResultFunc{
 	"dmarc": func() Result {
 		return new(DMARCResult)
 	},
+	"dkim-adsp": func() Result {
+		return new(DKIMADSPResult)
+	},
+	"bimi": func() Result {
+		return new(BIMIResult)
+	},
+	"arc": func() Result {
+		return new(ARCResult)
+	},
 }
 
+var (
+	// ErrUnsupportedVersion is returned when the header field version isn't
+	// supported.
+	ErrUnsupportedVersion = errors.New("msgauth: unsupported version")
+	// ErrMalformedMethod is returned when a result doesn't start with a
+	// method and a value.
+	ErrMalformedMethod = errors.New("msgauth: malformed authentication method and value")
+	// ErrEmptyInput is returned by ParseStrict when the header field doesn't
+	// contain any result.
+	ErrEmptyInput = errors.New("msgauth: empty header field")
+	// ErrEmptyMethod is returned when a result has a value but no method,
+	// e.g. "=pass".
+	ErrEmptyMethod = errors.New("msgauth: empty authentication method")
+	// ErrMalformedParam is returned by ParseStrict when a property or reason
+	// isn't a key and value pair.
+	ErrMalformedParam = errors.New("msgauth: malformed property")
+	// ErrUnsupportedMethodVersion is returned when the version of a known
+	// authentication method isn't supported.
+	ErrUnsupportedMethodVersion = errors.New("msgauth: unsupported authentication method version")
+	// ErrTooManyResults is returned by ParseWithLimit when the header field
+	// contains more results than allowed.
+	ErrTooManyResults = errors.New("msgauth: too many authentication results")
+	// ErrEmptyValue is returned by ParseStrict when a result has a method but
+	// no value, e.g. "dkim=". Parse treats such results as "none".
+	ErrEmptyValue = errors.New("msgauth: empty authentication result value")
+	// ErrDuplicateParam is reported in Parsed.Warnings when a result contains
+	// the same property more than once. The last value is kept.
+	ErrDuplicateParam = errors.New("msgauth: duplicate property")
+	// ErrControlChar is returned by ParseStrict when a property value contains
+	// control characters other than a tab. Parse replaces them with spaces.
+	ErrControlChar = errors.New("msgauth: control character in property")
+	// ErrUnterminatedQuote is reported in Parsed.Warnings, and returned by
+	// ParseStrict, when a quoted string isn't terminated. The quote is then
+	// parsed as a regular character.
+	ErrUnterminatedQuote = errors.New("msgauth: unterminated quoted string")
+)
+
+// ParseError is an error which occurred while parsing a header field.
+type ParseError struct {
+	// Token is the part of the header field which couldn't be parsed.
+	Token string
+	// Offset is the byte offset of Token in the header field.
+	Offset int
+	// Segment is the index of the semicolon-separated segment of the header
+	// field containing Token, starting from zero. For instance, the segment
+	// of "dkim=pass" is 1 in "example.org; dkim=pass".
+	Segment int
+	// Err is the underlying error, e.g. ErrMalformedMethod.
+	Err error
+}
+
+func (err *ParseError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// token is a part of a header field.
+type token struct {
+	s   string
+	off int // byte offset in the header field
+}
+
+func (t token) slice(i, j int) token {
+	return token{t.s[i:j], t.off + i}
+}
+
+func (t token) trimSpace() token {
+	s := strings.TrimLeftFunc(t.s, unicode.IsSpace)
+	return token{strings.TrimRightFunc(s, unicode.IsSpace), t.off + len(t.s) - len(s)}
+}
+
+func (t token) parseError(err error) *ParseError {
+	return &ParseError{Token: t.s, Offset: t.off, Err: err}
+}
+
+// Parser parses header fields with the provided options. The zero value
+// parses header fields as Parse does. A Parser is safe for concurrent use, as
+// long as its fields aren't modified.
+type Parser struct {
+	// Strict rejects malformed properties instead of ignoring them, see
+	// ParseStrict.
+	Strict bool
+	// KnownOnly drops the results with an unknown method, see
+	// ParseKnownOnly.
+	KnownOnly bool
+	// Lenient accepts commas as result separators, see ParseLenient.
+	Lenient bool
+	// MaxResults is the maximum number of results, if positive, see
+	// ParseWithLimit.
+	MaxResults int
+	// Methods is the set of known methods, in lowercase. If non-nil, the
+	// results of other methods are parsed as GenericResult, even if they're
+	// built-in or registered with RegisterMethod.
+	Methods map[string]bool
+	// DiscardComments drops the comments instead of recording them in
+	// Parsed.Comments and ResultBase.Comment.
+	DiscardComments bool
+}
+
+var defaultParser Parser
+
 // Parse parses the provided Authentication-Results header field. It returns the
 // authentication service identifier and authentication results.
+//
+// Malformed properties are ignored.
 func Parse(v string) *Parsed {
+	p, _ := defaultParser.Parse(v)
+	return p
+}
+
+// ParseStrict is like Parse, but rejects malformed properties instead of
+// ignoring them. The returned error, also available in Parsed.Error, lists all
+// the malformed properties.
+//
+// ErrEmptyInput is returned if v is empty, or only contains an identifier
+// without any result, e.g. "example.org". Use "example.org; none" to state
+// that no authentication was performed.
+func ParseStrict(v string) (*Parsed, error) {
+	return (&Parser{Strict: true}).Parse(v)
+}
+
+// ParseKnownOnly is like Parse, but drops the results whose method isn't
+// built-in or registered with RegisterMethod instead of returning them as
+// GenericResult. The methods of the dropped results are listed in
+// Parsed.Unknown. The returned error is Parsed.Error.
+func ParseKnownOnly(v string) (*Parsed, error) {
+	return (&Parser{KnownOnly: true}).Parse(v)
+}
+
+// ParseLenient is like Parse, but also accepts commas as result separators, as
+// emitted by some broken senders, e.g. "example.org; dkim=pass, spf=pass". A
+// comma is only considered as a separator when it's followed by a method and
+// value, so that commas in property values are preserved. The returned error
+// is Parsed.Error.
+func ParseLenient(v string) (*Parsed, error) {
+	return (&Parser{Lenient: true}).Parse(v)
+}
+
+// ParseWithLimit is like Parse, but stops after maxResults results and returns
+// ErrTooManyResults if the header field contains more. This bounds the memory
+// used to parse untrusted header fields. The results parsed before the limit
+// was reached are returned. A maxResults of zero or less means no limit. The
+// returned error is Parsed.Error.
+func ParseWithLimit(v string, maxResults int) (*Parsed, error) {
+	return (&Parser{MaxResults: maxResults}).Parse(v)
+}
+
+// Parse parses the provided Authentication-Results header field with the
+// options of p. The returned error is Parsed.Error.
+func (p *Parser) Parse(v string) (*Parsed, error) {
+	parsed := p.parse(v)
+	return parsed, parsed.Error
+}
+
+func (p *Parser) parse(v string) *Parsed {
 	var parResults []Result
-	parsed := &Parsed{}
-	parts := strings.Split(v, ";")
+	parsed := &Parsed{Version: 1}
+	raw := v
+	v, breaks := unfold(v)
+	v, comments := stripComments(v)
+	if p.DiscardComments {
+		comments = nil
+	}
+	for _, c := range comments {
+		parsed.Comments = append(parsed.Comments, c.s)
+	}
+	var buf [4]token
+	parts := splitQuoted(buf[:0], token{s: v}, isSemicolon)
+	segments := parts
+	errRefold := refolder{breaks: breaks}
+	locate := func(err *ParseError) *ParseError {
+		err.Segment = segmentIndex(segments, err.Offset)
+		err.Offset = errRefold.offset(err.Offset)
+		return err
+	}
+	var errs []error
+	if i := unterminatedQuote(v); i >= 0 {
+		t := token{s: v[i:], off: i}
+		if j := strings.IndexByte(t.s, ';'); j >= 0 {
+			t = t.slice(0, j)
+		}
+		t = t.trimSpace()
+		w := t.parseError(fmt.Errorf("%w %q", ErrUnterminatedQuote, t.s))
+		parsed.Warnings = append(parsed.Warnings, w.Error())
+		if p.Strict {
+			errs = append(errs, locate(w))
+		}
+	}
 	start := 1
-	parsed.Identifier = strings.TrimSpace(parts[0])
-	if strings.HasPrefix(parsed.Identifier, "i=") {
+	id := parts[0].trimSpace()
+	if k, v, ok := strings.Cut(id.s, "="); ok && strings.EqualFold(strings.TrimSpace(k), "i") {
 		// We are dealing with ARC-Authentication-Results
 		// https://www.rfc-editor.org/rfc/rfc8617.html#section-4.2.1
-		// Let's make sure
-		kv := strings.SplitN(parsed.Identifier, "=", 2)
-		if len(kv) == 2 {
-			ins, err := strconv.Atoi(kv[1])
-			// Instance tag values can range from 1-50 (inclusive).
-			if err == nil && ins > 0 && ins <= 50  {
-				parsed.Instance = ins
-				parsed.Identifier = strings.TrimSpace(parts[1])
-				start = 2
+		// Let's make sure. Comments have already been replaced with spaces,
+		// e.g. "i=1 (first hop)".
+		if ins := parseInstance(strings.TrimSpace(v)); ins > 0 {
+			parsed.Instance = ins
+			if len(parts) < 2 {
+				// Truncated header field, e.g. "i=1"
+				parsed.Error = locate(id.parseError(ErrMissingIdentifier))
+				return parsed
 			}
+			id = parts[1].trimSpace()
+			start = 2
 		}
 	}
-	i := strings.IndexFunc(parsed.Identifier, unicode.IsSpace)
-	if i > 0 {
+	parts = parts[start:]
+	// Only the identifier, e.g. "example.org"
+	idOnly := len(parts) == 0
+
+	// Some senders omit the authserv-id, or the semicolon between the
+	// authserv-id and the first result
+	fields := splitFields(nil, id)
+	if len(fields) > 0 && !strings.Contains(fields[0].s, "=") {
+		parsed.Identifier = fields[0].s
+		fields = fields[1:]
+
+		// Some receivers append their address to the authserv-id, e.g.
+		// "mx.example.org [192.0.2.1]"
+		if len(fields) > 0 && isAddressLiteral(fields[0].s) {
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 && isDigits(fields[0].s) {
 		// Authentication-Results: example.org 1;
-		version := strings.TrimSpace(parsed.Identifier[i:])
-		if version != "1" {
+		parsed.Version, _ = strconv.Atoi(fields[0].s)
+		parsed.ExplicitVersion = true
+		if fields[0].s != "1" {
 			parsed.Identifier = ""
-			parsed.Results = nil
-			parsed.Error = errors.New("msgauth: unsupported version")
+			parsed.Error = locate(fields[0].parseError(ErrUnsupportedVersion))
 			return parsed
 		}
-
-		parsed.Identifier = parsed.Identifier[:i]
+		fields = fields[1:]
+	}
+	if len(fields) > 0 {
+		first := id.slice(fields[0].off-id.off, len(id.s))
+		parts = append([]token{first}, parts...)
+	}
+	if p.Lenient {
+		var l []token
+		for _, t := range parts {
+			l = append(l, splitCommas(t)...)
+		}
+		parts = l
 	}
 
-
-	for i := start; i < len(parts); i++ {
-		s := strings.TrimSpace(parts[i])
-		if s == "" {
+	rawRefold := refolder{breaks: breaks}
+	nextComment := 0
+	for _, t := range parts {
+		if s := strings.TrimSpace(t.s); s == "" {
+			continue
+		} else if strings.EqualFold(s, "none") {
+			parsed.None = true
 			continue
 		}
 
-		result, err := parseResult(s)
+		if p.MaxResults > 0 && len(parResults) >= p.MaxResults {
+			parsed.Results = parResults
+			parsed.Error = locate(t.trimSpace().parseError(ErrTooManyResults))
+			return parsed
+		}
+
+		// Only pass the comments of this result, comments are in order
+		for nextComment < len(comments) && comments[nextComment].off < t.off {
+			nextComment++
+		}
+		end := nextComment
+		for end < len(comments) && comments[end].off < t.off+len(t.s) {
+			end++
+		}
+		result, warnings, err := p.parseResult(t, comments[nextComment:end])
+		nextComment = end
+		for _, w := range warnings {
+			parsed.Warnings = append(parsed.Warnings, w.Error())
+			if p.Strict && !errors.Is(w, ErrDuplicateParam) {
+				errs = append(errs, locate(w))
+			}
+		}
 		if err != nil {
 			parsed.Results = parResults
-			parsed.Error = err
+			if errs == nil {
+				parsed.Error = locate(err)
+			} else {
+				parsed.Error = errors.Join(append(errs, locate(err))...)
+			}
 			return parsed
 		}
+		if generic, ok := result.(*GenericResult); ok && p.KnownOnly {
+			parsed.Unknown = append(parsed.Unknown, generic.Method)
+			continue
+		}
 		if result != nil {
+			start := rawRefold.offset(t.off)
+			end := rawRefold.offset(t.off + len(t.s))
+			result.base().Raw = raw[start:end]
+			result.base().ARCInstance = parsed.Instance
 			parResults = append(parResults, result)
 			parsed.Results = parResults
 		}
 	}
+	if p.Strict && idOnly && len(parsed.Results) == 0 && !parsed.None {
+		errs = append(errs, ErrEmptyInput)
+	}
+	parsed.Error = errors.Join(errs...)
 	return parsed
 }
 
-func parseResult(s string) (Result, error) {
-	// TODO: ignore header comments in parenthesis
+// ParseMultiple parses multiple Authentication-Results header fields, e.g. all
+// the fields of a message. A malformed header field doesn't prevent the others
+// from being parsed: its error is available in the Error field of its Parsed
+// value. The returned error joins the errors of all the header fields.
+func ParseMultiple(values []string) ([]*Parsed, error) {
+	l := make([]*Parsed, len(values))
+	var errs []error
+	for i, v := range values {
+		l[i] = Parse(v)
+		if l[i].Error != nil {
+			errs = append(errs, l[i].Error)
+		}
+	}
+	return l, errors.Join(errs...)
+}
 
-	parts := strings.Fields(s)
-	if len(parts) == 0 || parts[0] == "none" {
-		return nil, nil
+// FilterByIdentifier returns the header fields whose authentication service
+// identifier is id. Identifiers are compared case-insensitively, ignoring a
+// trailing dot.
+func FilterByIdentifier(parsed []*Parsed, id string) []*Parsed {
+	var l []*Parsed
+	for _, p := range parsed {
+		if p.IdentifierMatches(id) {
+			l = append(l, p)
+		}
 	}
+	return l
+}
 
-	k, v, err := parseParam(parts[0])
-	if err != nil {
-		return nil, err
+// RemoveByIdentifier returns the header field values which weren't added by
+// the authentication service id, e.g. to remove forged header fields claiming
+// to come from the local server before verifying a message. Identifiers are
+// compared as in FilterByIdentifier. The remaining header field values are
+// returned unchanged.
+//
+// Header field values starting with id are removed even if they can't be
+// parsed, e.g. "mx.example.org 2; dkim=pass" with an unsupported version.
+func RemoveByIdentifier(values []string, id string) []string {
+	var l []string
+	for _, v := range values {
+		if !Parse(v).IdentifierMatches(id) && !identifierEqual(rawIdentifier(v), id) {
+			l = append(l, v)
+		}
 	}
-	method, value := k, ResultValue(strings.ToLower(v))
+	return l
+}
 
-	params := make(map[string]string)
+// rawIdentifier returns the first word of the authentication service
+// identifier section of a header field value, skipping any ARC instance, even
+// if the header field value is malformed. It returns an empty string if there
+// is none.
+func rawIdentifier(v string) string {
+	v, _ = unfold(v)
+	v, _ = stripComments(v)
+	parts := splitQuoted(nil, token{s: v}, isSemicolon)
+	if len(parts) == 0 {
+		return ""
+	}
+	id := parts[0].trimSpace()
+	if k, _, ok := strings.Cut(id.s, "="); ok && strings.EqualFold(strings.TrimSpace(k), "i") {
+		if len(parts) < 2 {
+			return ""
+		}
+		id = parts[1].trimSpace()
+	}
+	fields := splitFields(nil, id)
+	if len(fields) == 0 || strings.Contains(fields[0].s, "=") {
+		return ""
+	}
+	return fields[0].s
+}
+
+// parseResult parses a single result. Malformed properties are ignored and
+// returned as warnings, as well as duplicate properties. comments contains the
+// comments found in t.
+func (p *Parser) parseResult(t token, comments []token) (Result, []*ParseError, *ParseError) {
+	var buf [8]token
+	parts := splitFields(buf[:0], t)
+	if len(parts) == 0 {
+		return nil, nil, nil
+	}
+
+	// Some producers emit the reason before the method and value
+	if i := methodField(parts); i < 0 {
+		return nil, nil, parts[0].parseError(ErrMalformedMethod)
+	} else if i > 0 {
+		parts[0], parts[i] = parts[i], parts[0]
+	}
+
+	// A comment immediately following the method and value is attached to
+	// the result
+	var comment string
+	commentEnd := t.off + len(t.s)
+	if len(parts) > 1 {
+		commentEnd = parts[1].off
+	}
+	for _, c := range comments {
+		if c.off >= parts[0].off+len(parts[0].s) && c.off < commentEnd {
+			comment = c.s
+			break
+		}
+	}
+
+	rawMethod, v, ok := strings.Cut(parts[0].s, "=")
+	if !ok {
+		return nil, nil, parts[0].parseError(ErrMalformedMethod)
+	}
+	value := ResultValue(strings.ToLower(unquote(strings.TrimSpace(v))))
+
+	method, version := normalizeMethod(rawMethod)
+	if version < 0 {
+		return nil, nil, parts[0].parseError(ErrMalformedMethod)
+	}
+	if method == "" {
+		return nil, nil, parts[0].parseError(ErrEmptyMethod)
+	}
+
+	var r Result
+	if p.Methods != nil && !p.Methods[method] {
+		r = &GenericResult{Method: method}
+	} else {
+		var err error
+		if r, err = newMethodResult(method, version); err != nil {
+			return nil, nil, parts[0].parseError(err)
+		}
+	}
+	_, needProps := r.(propertiesParser)
+	_, generic := r.(genericResult)
+
+	var warnings []*ParseError
+	if value == "" {
+		value = ResultNone
+		warnings = append(warnings, parts[0].parseError(ErrEmptyValue))
+	}
+
+	// Most results have a single property, avoid allocating for the others
+	var params map[string]string
+	if len(parts) > 1 || generic {
+		params = make(map[string]string, len(parts)-1)
+	}
+	var props []Property
 	for i := 1; i < len(parts); i++ {
-		k, v, err := parseParam(parts[i])
-		if err != nil {
+		k, v, err := parseParam(parts[i].s)
+		if err != nil || k == "" || v == "" {
+			err = fmt.Errorf("%w %q", ErrMalformedParam, parts[i].s)
+			warnings = append(warnings, parts[i].parseError(err))
+		}
+		if err != nil || k == "" {
 			continue
 		}
 
+		if _, ok := params[k]; ok {
+			err = fmt.Errorf("%w %q", ErrDuplicateParam, parts[i].s)
+			warnings = append(warnings, parts[i].parseError(err))
+		}
+		if s, ok := sanitizeValue(v); !ok {
+			err = fmt.Errorf("%w %q", ErrControlChar, parts[i].s)
+			warnings = append(warnings, parts[i].parseError(err))
+			v = s
+		}
 		params[k] = v
+		if !needProps {
+			continue
+		}
+		rawKey, _, _ := strings.Cut(parts[i].s, "=")
+		if prop, ok := parseProperty(rawKey, v); ok {
+			props = append(props, prop)
+		}
 	}
 
-	newResult, ok := results[method]
+	fillResult(r, value, params, props)
+	r.base().setVersion(version)
+	r.base().Comment = comment
+	return r, warnings, nil
+}
 
-	var r Result
-	if ok {
-		r = newResult()
-	} else {
-		r = &GenericResult{
-			Method: method,
-			Value:  value,
-			Params: params,
+// sanitizeValue replaces the control characters of a property value with
+// spaces. Tabs are kept. It returns false if v contained control characters.
+func sanitizeValue(v string) (string, bool) {
+	if strings.IndexFunc(v, isControl) < 0 {
+		return v, true
+	}
+	return strings.Map(func(r rune) rune {
+		if isControl(r) {
+			return ' '
 		}
+		return r
+	}, v), false
+}
+
+func isControl(r rune) bool {
+	return (r < ' ' && r != '\t') || r == 0x7f
+}
+
+// normalizeMethod returns the lowercase name and the version of a raw method,
+// e.g. "DKIM/1" yields "dkim" and 1. The version is 0 if there is none and -1
+// if it is malformed.
+func normalizeMethod(raw string) (name string, version int) {
+	name = strings.ToLower(strings.TrimSpace(raw))
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		v, err := strconv.Atoi(strings.TrimSpace(name[i+1:]))
+		if err != nil || v < 1 {
+			return strings.TrimSpace(name[:i]), -1
+		}
+		name, version = strings.TrimSpace(name[:i]), v
 	}
+	return name, version
+}
 
+// fillResult populates r from its value, params and properties.
+func fillResult(r Result, value ResultValue, params map[string]string, props []Property) {
 	r.parse(value, params)
-	return r, nil
+	if pp, ok := r.(propertiesParser); ok {
+		pp.parseProperties(props)
+	}
+	r.base().Extra = extraParams(r, params)
+	r.base().Policy = policyParams(params)
+}
+
+// extraParams returns the params which aren't mapped to a field of r, or nil
+// if there are none.
+func extraParams(r Result, params map[string]string) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+
+	_, formatted := r.format()
+	var extra map[string]string
+	for k, v := range params {
+		if k == "reason" || hasKeyFold(formatted, k) {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]string)
+		}
+		extra[k] = v
+	}
+	return extra
+}
+
+// policyParams returns the policy properties of params, without their
+// "policy." prefix.
+func policyParams(params map[string]string) map[string]string {
+	var policy map[string]string
+	for k, v := range params {
+		name, ok := strings.CutPrefix(k, string(PropertyPolicy)+".")
+		if !ok {
+			continue
+		}
+		if policy == nil {
+			policy = make(map[string]string)
+		}
+		policy[name] = v
+	}
+	return policy
+}
+
+// hasKeyFold reports whether m contains the lowercase key k, ignoring the case
+// of the keys of m.
+func hasKeyFold(m map[string]string, k string) bool {
+	if _, ok := m[k]; ok {
+		return true
+	}
+	for mk := range m {
+		if strings.EqualFold(mk, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineBreak is a line break removed when unfolding a header field.
+type lineBreak struct {
+	off int // offset in the unfolded header field
+	n   int // length of the line break
+}
+
+// unfold removes the line breaks followed by whitespace from s, as described
+// in RFC 5322 section 2.2.3. Both CRLF and LF line breaks are accepted.
+func unfold(s string) (string, []lineBreak) {
+	if strings.IndexByte(s, '\n') < 0 {
+		return s, nil
+	}
+
+	var b strings.Builder
+	var breaks []lineBreak
+	for i := 0; i < len(s); i++ {
+		n := 0
+		if strings.HasPrefix(s[i:], "\r\n") {
+			n = 2
+		} else if s[i] == '\n' {
+			n = 1
+		}
+		if n > 0 && i+n < len(s) && (s[i+n] == ' ' || s[i+n] == '\t') {
+			breaks = append(breaks, lineBreak{b.Len(), n})
+			i += n - 1
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), breaks
+}
+
+// segmentIndex returns the index of the segment containing the byte at offset
+// off.
+func segmentIndex(segments []token, off int) int {
+	// Index of the first segment starting after off
+	i := sort.Search(len(segments), func(i int) bool {
+		return segments[i].off > off
+	})
+	if i == 0 {
+		return 0
+	}
+	return i - 1
+}
+
+// refoldOffset converts an offset in the unfolded header field to an offset in
+// the original one.
+func refoldOffset(off int, breaks []lineBreak) int {
+	orig := off
+	for _, br := range breaks {
+		if br.off > off {
+			break
+		}
+		orig += br.n
+	}
+	return orig
+}
+
+// refolder converts offsets in the unfolded header field to offsets in the
+// original one, like refoldOffset. Offsets are expected in increasing order,
+// so that the line breaks are only walked once.
+type refolder struct {
+	breaks []lineBreak
+	i      int // index of the next line break
+	n      int // total length of the line breaks before the next one
+	last   int
+}
+
+func (r *refolder) offset(off int) int {
+	if off < r.last {
+		return refoldOffset(off, r.breaks)
+	}
+	r.last = off
+	for r.i < len(r.breaks) && r.breaks[r.i].off <= off {
+		r.n += r.breaks[r.i].n
+		r.i++
+	}
+	return off + r.n
+}
+
+// stripComments removes comments in parentheses from s, replacing them with
+// spaces so that the offsets of the remaining bytes are unchanged. It returns
+// the text of the removed comments, along with the offsets of their opening
+// parenthesis. Nested comments are kept as part of the outer one, and an
+// unterminated comment extends to the end of s. Parentheses inside quoted
+// strings are left as-is.
+func stripComments(s string) (string, []token) {
+	if strings.IndexByte(s, '(') < 0 {
+		return s, nil
+	}
+
+	b := []byte(s)
+	var comment strings.Builder
+	var comments []token
+	depth := 0
+	start := 0
+	quoted := false
+	for i := 0; i < len(b); i++ {
+		ch := b[i]
+		if depth == 0 {
+			switch {
+			case quoted && ch == '\\':
+				i++
+			case ch == '"':
+				quoted = !quoted
+			case !quoted && ch == '(':
+				depth++
+				start = i
+				b[i] = ' '
+			}
+			continue
+		}
+
+		b[i] = ' '
+		switch ch {
+		case '\\':
+			// quoted-pair
+			if i+1 < len(b) {
+				i++
+				comment.WriteByte(b[i])
+				b[i] = ' '
+			}
+			continue
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				comments = append(comments, token{normalizeComment(comment.String()), start})
+				comment.Reset()
+				continue
+			}
+		}
+		comment.WriteByte(ch)
+	}
+	if depth > 0 {
+		comments = append(comments, token{normalizeComment(comment.String()), start})
+	}
+
+	return string(b), comments
+}
+
+// normalizeComment collapses folding whitespace in a comment.
+func normalizeComment(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// isAddressLiteral reports whether s is an address literal in square
+// brackets, e.g. "[192.0.2.1]".
+func isAddressLiteral(s string) bool {
+	return len(s) >= 2 && s[0] == '[' && s[len(s)-1] == ']'
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isSemicolon(ch byte) bool {
+	return ch == ';'
+}
+
+func isComma(ch byte) bool {
+	return ch == ','
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n'
+}
+
+// unterminatedQuote returns the index of the opening quote of the quoted string
+// which isn't terminated in s, or -1 if there is none.
+func unterminatedQuote(s string) int {
+	open := -1
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case open >= 0 && ch == '\\':
+			i++
+		case ch == '"' && open >= 0:
+			open = -1
+		case ch == '"':
+			open = i
+		}
+	}
+	return open
+}
+
+// splitQuoted slices t into all substrings separated by bytes for which isSep
+// returns true, and appends them to parts. Separators inside quoted strings are
+// ignored. Quotes from an unterminated quoted string onwards are parsed as
+// regular characters, so that it doesn't swallow the rest of t.
+func splitQuoted(parts []token, t token, isSep func(ch byte) bool) []token {
+	open := unterminatedQuote(t.s)
+	if open < 0 {
+		open = len(t.s)
+	}
+	start := 0
+	quoted := false
+	for i := 0; i < len(t.s); i++ {
+		switch ch := t.s[i]; {
+		case quoted && ch == '\\':
+			i++
+		case ch == '"' && i < open:
+			quoted = !quoted
+		case !quoted && isSep(ch):
+			parts = append(parts, t.slice(start, i))
+			start = i + 1
+		}
+	}
+	return append(parts, t.slice(start, len(t.s)))
+}
+
+// splitFields splits t around whitespace, keeping quoted strings intact, and
+// appends the fields to fields.
+// Whitespace surrounding "=" is allowed, e.g. when a comment has been removed
+// between a key and its value.
+func splitFields(fields []token, t token) []token {
+	var buf [8]token
+	for _, f := range splitQuoted(buf[:0], t, isSpace) {
+		if f.s == "" {
+			continue
+		}
+
+		if n := len(fields); n > 0 && joinFields(fields[n-1].s, f.s) {
+			fields[n-1].s += f.s
+		} else {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// splitCommas splits the results of t separated by commas instead of
+// semicolons. Commas which aren't followed by a method and value are kept.
+func splitCommas(t token) []token {
+	var l []token
+	for _, part := range splitQuoted(nil, t, isComma) {
+		if n := len(l); n > 0 && !isMethodValue(part.s) {
+			l[n-1] = t.slice(l[n-1].off-t.off, part.off-t.off+len(part.s))
+		} else {
+			l = append(l, part)
+		}
+	}
+	return l
+}
+
+// isMethodValue reports whether s starts with a method and value, e.g.
+// "spf=pass".
+func isMethodValue(s string) bool {
+	s = strings.TrimLeft(s, " \t")
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		s = s[:i]
+	}
+	k, v, ok := strings.Cut(s, "=")
+	return ok && k != "" && v != "" && !strings.Contains(k, ".")
+}
+
+// methodField returns the index of the field containing the method and value
+// of a result, skipping any leading reason. It returns -1 if a leading reason
+// isn't followed by a method and value, e.g. "reason=x header.d=example.org".
+func methodField(fields []token) int {
+	for i, f := range fields {
+		k, _, _ := strings.Cut(f.s, "=")
+		if strings.EqualFold(strings.TrimSpace(k), "reason") {
+			continue
+		}
+		if !strings.Contains(k, ".") || i == 0 {
+			return i
+		}
+		break
+	}
+	return -1
+}
+
+// joinFields reports whether two fields separated by whitespace are the key
+// and the value of a single param.
+func joinFields(prev, next string) bool {
+	if strings.HasPrefix(next, "=") {
+		return !strings.Contains(prev, "=")
+	}
+	return strings.HasSuffix(prev, "=") && (next[0] == '"' || !strings.Contains(next, "="))
+}
+
+// unquote decodes s if it's a quoted string. Other values, including values
+// which only partly consist of a quoted string such as a quoted local-part,
+// are returned unchanged.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		ch := s[i]
+		switch {
+		case ch == '\\' && i+1 < len(s)-1:
+			i++
+			ch = s[i]
+		case ch == '"':
+			return s
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
 }
 
 func parseParam(s string) (k string, v string, err error) {
-	kv := strings.SplitN(s, "=", 2)
-	if len(kv) != 2 {
-		return "", "", errors.New("msgauth: malformed authentication method and value")
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", "", ErrMalformedMethod
 	}
-	return strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1]), nil
+	return strings.ToLower(strings.TrimSpace(k)), unquote(strings.TrimSpace(v)), nil
 }
@@ -1,8 +1,14 @@
 package authres
 
 import (
+	"bytes"
+	"errors"
+	"net"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 var parseTests = []msgauthTest{
@@ -24,19 +30,48 @@ var parseTests = []msgauthTest{
 			&SPFResult{Value: ResultPass, From: "example.net"},
 		},
 	},
+	{
+		value:      "mx.example.com spf=pass smtp.mailfrom=a@example.net",
+		identifier: "mx.example.com",
+		results: []Result{
+			&SPFResult{Value: ResultPass, From: "a@example.net"},
+		},
+	},
+	{
+		value:      "mx.example.com 1 spf=pass smtp.mailfrom=a@example.net; dkim=pass header.d=example.net",
+		identifier: "mx.example.com",
+		results: []Result{
+			&SPFResult{Value: ResultPass, From: "a@example.net"},
+			&DKIMResult{Value: ResultPass, Domain: "example.net"},
+		},
+	},
+	{
+		value:      "spf=pass smtp.mailfrom=a@example.net; dkim=pass header.d=example.net",
+		identifier: "",
+		results: []Result{
+			&SPFResult{Value: ResultPass, From: "a@example.net"},
+			&DKIMResult{Value: ResultPass, Domain: "example.net"},
+		},
+	},
 	{
 		value: "example.com;" +
 			" auth=pass (cram-md5) smtp.auth=sender@example.com;",
 		identifier: "example.com",
 		results: []Result{
-			&AuthResult{Value: ResultPass, Auth: "sender@example.com"},
+			&AuthResult{ResultBase: ResultBase{Comment: "cram-md5"}, Value: ResultPass, Auth: "sender@example.com"},
 		},
 	},
 }
 
 func TestParse(t *testing.T) {
-	for _, test := range append(msgauthTests, parseTests...) {
-		identifier, results, err := Parse(test.value)
+	tests := append(append(msgauthTests, parseTests...), parseQuotedTests...)
+	tests = append(append(tests, parseARCTests...), parseGenericTests...)
+	tests = append(append(tests, parseFoldedTests...), parseReasonTests...)
+	tests = append(append(tests, parseIdentifierTests...), parseCaseTests...)
+	tests = append(tests, parseEmptySectionTests...)
+	for _, test := range tests {
+		parsed := Parse(test.value)
+		identifier, results, err := parsed.Identifier, clearRaw(parsed.Results), parsed.Error
 		if err != nil {
 			t.Errorf("Expected no error when parsing header, got: %v", err)
 		} else if test.identifier != identifier {
@@ -52,3 +87,1493 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+// clearRaw clears the Raw field of results, as well as the default version, so
+// that they can be compared with the expected ones.
+func clearRaw(results []Result) []Result {
+	for _, r := range results {
+		b := r.base()
+		b.Raw = ""
+		if !b.ExplicitVersion && b.Version == 1 {
+			b.Version = 0
+		}
+	}
+	return results
+}
+
+func TestParse_raw(t *testing.T) {
+	v := "example.com;\r\n\tspf=pass (sender\r\n  ok) smtp.mailfrom=example.net ;dkim=pass\r\n header.d=example.org"
+	want := []string{"\tspf=pass (sender\r\n  ok) smtp.mailfrom=example.net ", "dkim=pass\r\n header.d=example.org"}
+
+	parsed := Parse(v)
+	if parsed.Error != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", v, parsed.Error)
+	}
+	if len(parsed.Results) != len(want) {
+		t.Fatalf("Parse(%q): expected %v results, got %v", v, len(want), len(parsed.Results))
+	}
+	for i, r := range parsed.Results {
+		if raw := r.base().Raw; raw != want[i] {
+			t.Errorf("Parse(%q): expected raw result #%v to be %q, got %q", v, i, want[i], raw)
+		}
+	}
+}
+
+var parseIdentifierTests = []msgauthTest{
+	{
+		value:      "mx.example.com:25; spf=pass smtp.mailfrom=example.net",
+		identifier: "mx.example.com:25",
+		results: []Result{
+			&SPFResult{Value: ResultPass, From: "example.net"},
+		},
+	},
+	{
+		value:      "mx.example.com [10.0.0.1]; spf=pass smtp.mailfrom=example.net",
+		identifier: "mx.example.com",
+		results: []Result{
+			&SPFResult{Value: ResultPass, From: "example.net"},
+		},
+	},
+	{
+		value:      "mx.example.com [IPv6:2001:db8::1] 1; dkim=pass header.d=example.org",
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value:      "mx.example.com [10.0.0.1] spf=pass",
+		identifier: "mx.example.com",
+		results: []Result{
+			&SPFResult{Value: ResultPass},
+		},
+	},
+}
+
+var parseEmptySectionTests = []msgauthTest{
+	{
+		value:      "mx.example.com;; dkim=pass;;",
+		identifier: "mx.example.com",
+		results:    []Result{&DKIMResult{Value: ResultPass}},
+	},
+	{
+		value:      "mx.example.com; ; dkim=pass",
+		identifier: "mx.example.com",
+		results:    []Result{&DKIMResult{Value: ResultPass}},
+	},
+	{
+		value:      "mx.example.com; dkim=pass; ;\r\n\t; spf=fail",
+		identifier: "mx.example.com",
+		results:    []Result{&DKIMResult{Value: ResultPass}, &SPFResult{Value: ResultFail}},
+	},
+	{
+		value:      "mx.example.com;;;",
+		identifier: "mx.example.com",
+	},
+	{
+		value:      "i=1; mx.example.com;;; ;",
+		identifier: "mx.example.com",
+	},
+	{
+		value: ";;;",
+	},
+}
+
+func TestParse_emptySections(t *testing.T) {
+	for _, test := range parseEmptySectionTests {
+		for _, parse := range []func(string) (*Parsed, error){ParseStrict, ParseLenient} {
+			parsed, err := parse(test.value)
+			if err != nil {
+				t.Errorf("Parse(%q): unexpected error: %v", test.value, err)
+				continue
+			}
+			for _, r := range parsed.Results {
+				if r == nil {
+					t.Errorf("Parse(%q): unexpected nil result in %v", test.value, parsed.Results)
+				}
+			}
+		}
+	}
+}
+
+var parseCaseTests = []msgauthTest{
+	{
+		value:      "example.com; DKIM=pass Header.D=example.org",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value:      "example.com; Dkim=PASS HEADER.d=example.org header.S=brisbane",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultPass, Domain: "example.org", Selector: "brisbane"},
+		},
+	},
+	{
+		value:      "example.com; dkim=pass header.d=example.org; SPF=Fail SMTP.MailFrom=Example.NET",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultPass, Domain: "example.org"},
+			&SPFResult{Value: ResultFail, From: "Example.NET"},
+		},
+	},
+	{
+		value:      "example.com; DMARC/1=pass Policy.DMARC=none",
+		identifier: "example.com",
+		results: []Result{
+			&DMARCResult{
+				ResultBase:  ResultBase{Version: 1, ExplicitVersion: true, Policy: map[string]string{"dmarc": "none"}},
+				Value:       ResultPass,
+				Disposition: "none",
+			},
+		},
+	},
+}
+
+var parseFoldedTests = []msgauthTest{
+	{
+		value: "mx.google.com;\r\n" +
+			"       dkim=pass header.i=@example.org header.s=20230601 header.b=AbCdEfGh;\r\n" +
+			"       spf=pass (google.com: domain of sender@example.org designates\r\n" +
+			"       192.0.2.1 as permitted sender) smtp.mailfrom=sender@example.org;\r\n" +
+			"       dmarc=pass (p=NONE sp=NONE dis=NONE) header.from=example.org",
+		identifier: "mx.google.com",
+		results: []Result{
+			&DKIMResult{Value: ResultPass, Identifier: "@example.org", Selector: "20230601", Signature: "AbCdEfGh"},
+			&SPFResult{
+				ResultBase: ResultBase{
+					Comment: "google.com: domain of sender@example.org designates 192.0.2.1 as permitted sender",
+				},
+				Value: ResultPass,
+				From:  "sender@example.org",
+			},
+			&DMARCResult{ResultBase: ResultBase{Comment: "p=NONE sp=NONE dis=NONE"}, Value: ResultPass, From: "example.org"},
+		},
+	},
+	{
+		value:      "mx.example.com\r\n 1;\n\tdkim=fail reason=\"bad\r\n signature\" header.d=example.org",
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultFail, Reason: "bad signature", Domain: "example.org"},
+		},
+	},
+}
+
+var parseARCTests = []msgauthTest{
+	{
+		value:      "example.com; arc=fail arc.i=3 arc.oldest-pass=invalid",
+		identifier: "example.com",
+		results: []Result{
+			&ARCResult{Value: ResultFail, Instance: 3},
+		},
+	},
+}
+
+var parseGenericTests = []msgauthTest{
+	{
+		value:      "example.com; x-dnswl=pass reason=listed dns.zone=list.example.org header.zone=example.net",
+		identifier: "example.com",
+		results: []Result{
+			&GenericResult{
+				Method: "x-dnswl",
+				Value:  ResultPass,
+				Params: map[string]string{
+					"reason":      "listed",
+					"dns.zone":    "list.example.org",
+					"header.zone": "example.net",
+				},
+				Properties: []Property{
+					{Type: PropertyDNS, Name: "zone", Value: "list.example.org"},
+					{Type: PropertyHeader, Name: "zone", Value: "example.net"},
+				},
+			},
+		},
+	},
+}
+
+var parseQuotedTests = []msgauthTest{
+	{
+		value: "example.com;" +
+			" dkim=fail reason=\"hash mismatch; see docs\" header.d=example.org;" +
+			" spf=pass smtp.mailfrom=example.net",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultFail, Reason: "hash mismatch; see docs", Domain: "example.org"},
+			&SPFResult{Value: ResultPass, From: "example.net"},
+		},
+	},
+	{
+		value: "example.com;" +
+			" dkim=fail reason=\"a \\\"b; c\\\" d;\"",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultFail, Reason: `a "b; c" d;`},
+		},
+	},
+	{
+		value: "example.com;" +
+			" dkim=fail reason=\"signature ok; trust me\" header.d=example.com",
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultFail, Reason: "signature ok; trust me", Domain: "example.com"},
+		},
+	},
+	{
+		value: "example.com;" +
+			" spf=fail reason=\"a \\\"quoted\\\" \\\\ (reason)\" smtp.mailfrom=example.net;" +
+			" dkim=pass header.d=example.org",
+		identifier: "example.com",
+		results: []Result{
+			&SPFResult{Value: ResultFail, Reason: `a "quoted" \ (reason)`, From: "example.net"},
+			&DKIMResult{Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value: "example.com;" +
+			" spf=pass smtp.mailfrom=\"john smith\"@example.net",
+		identifier: "example.com",
+		results: []Result{
+			&SPFResult{Value: ResultPass, From: `"john smith"@example.net`},
+		},
+	},
+	{
+		value:      `example.com; dkim=pass header.i="foo; spf=pass`,
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultPass, Identifier: `"foo`},
+			&SPFResult{Value: ResultPass},
+		},
+	},
+}
+
+var parseReasonTests = []msgauthTest{
+	{
+		value:      `example.com; dkim=fail reason="body hash did not verify" header.d=x`,
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultFail, Reason: "body hash did not verify", Domain: "x"},
+		},
+	},
+	{
+		value:      `example.com; reason="body hash did not verify" dkim=fail header.d=x`,
+		identifier: "example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultFail, Reason: "body hash did not verify", Domain: "x"},
+		},
+	},
+	{
+		value:      `example.com; spf=fail REASON = "not permitted" smtp.mailfrom=example.net`,
+		identifier: "example.com",
+		results: []Result{
+			&SPFResult{Value: ResultFail, Reason: "not permitted", From: "example.net"},
+		},
+	},
+	{
+		value:      `example.com; reason=nxdomain iprev=fail policy.iprev=192.0.2.1; dmarc=fail reason="p=reject"`,
+		identifier: "example.com",
+		results: []Result{
+			&IPRevResult{
+				ResultBase: ResultBase{Policy: map[string]string{"iprev": "192.0.2.1"}},
+				Value:      ResultFail,
+				Reason:     "nxdomain",
+				IP:         "192.0.2.1",
+			},
+			&DMARCResult{Value: ResultFail, Reason: "p=reject"},
+		},
+	},
+}
+
+var parseCommentsTests = []struct {
+	value    string
+	results  []Result
+	comments []string
+}{
+	{
+		value: "example.com; dkim=pass (good signature) header.d=example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{Comment: "good signature"}, Value: ResultPass, Domain: "example.com"},
+		},
+		comments: []string{"good signature"},
+	},
+	{
+		value: "example.com; dkim=pass (a=b (nested) \\) c=d) header.d=example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{Comment: "a=b (nested) ) c=d"}, Value: ResultPass, Domain: "example.com"},
+		},
+		comments: []string{"a=b (nested) ) c=d"},
+	},
+	{
+		value: "example.com; dkim(method comment)=pass header.d=example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultPass, Domain: "example.com"},
+		},
+		comments: []string{"method comment"},
+	},
+	{
+		value: "example.com; spf=pass (sender\r\n\tauthorized; folded) smtp.mailfrom=example.net",
+		results: []Result{
+			&SPFResult{ResultBase: ResultBase{Comment: "sender authorized; folded"}, Value: ResultPass, From: "example.net"},
+		},
+		comments: []string{"sender authorized; folded"},
+	},
+	{
+		value: "example.com; spf=pass smtp.mailfrom=example.net (unterminated",
+		results: []Result{
+			&SPFResult{Value: ResultPass, From: "example.net"},
+		},
+		comments: []string{"unterminated"},
+	},
+	{
+		value: "example.com; spf=pass smtp.mailfrom=example.net (about mailfrom); dkim=pass (ok)",
+		results: []Result{
+			&SPFResult{Value: ResultPass, From: "example.net"},
+			&DKIMResult{ResultBase: ResultBase{Comment: "ok"}, Value: ResultPass},
+		},
+		comments: []string{"about mailfrom", "ok"},
+	},
+}
+
+func TestParse_comments(t *testing.T) {
+	for _, test := range parseCommentsTests {
+		parsed := Parse(test.value)
+		if parsed.Error != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.value, parsed.Error)
+			continue
+		}
+		if !reflect.DeepEqual(test.results, clearRaw(parsed.Results)) {
+			t.Errorf("Parse(%q): expected results \n%v\n but got \n%v", test.value, test.results, parsed.Results)
+		}
+		if !reflect.DeepEqual(test.comments, parsed.Comments) {
+			t.Errorf("Parse(%q): expected comments %q but got %q", test.value, test.comments, parsed.Comments)
+		}
+	}
+}
+
+var parseErrorTests = []struct {
+	value   string
+	err     error
+	token   string
+	off     int
+	segment int
+}{
+	{
+		value:   "mx.example.com; =pass smtp.mailfrom=a@b.com",
+		err:     ErrEmptyMethod,
+		token:   "=pass",
+		off:     16,
+		segment: 1,
+	},
+	{
+		value:   "mx.example.com; spf=pass; /1=pass",
+		err:     ErrEmptyMethod,
+		token:   "/1=pass",
+		off:     26,
+		segment: 2,
+	},
+	{
+		value:   "example.com 2; none",
+		err:     ErrUnsupportedVersion,
+		token:   "2",
+		off:     12,
+		segment: 0,
+	},
+	{
+		value:   "example.com; dkim/2=pass header.d=example.org",
+		err:     ErrUnsupportedMethodVersion,
+		token:   "dkim/2=pass",
+		off:     13,
+		segment: 1,
+	},
+	{
+		value:   "example.com; dkim/x=pass header.d=example.org",
+		err:     ErrMalformedMethod,
+		token:   "dkim/x=pass",
+		off:     13,
+		segment: 1,
+	},
+	{
+		value:   "example.com; spf=pass; dkim (comment) header.d=example.org",
+		err:     ErrMalformedMethod,
+		token:   "dkim",
+		off:     23,
+		segment: 2,
+	},
+	{
+		value:   "example.com;\r\n spf=pass;\r\n dkim (comment) header.d=example.org",
+		err:     ErrMalformedMethod,
+		token:   "dkim",
+		off:     27,
+		segment: 2,
+	},
+	{
+		value:   `example.com; reason="no method" header.d=example.org`,
+		err:     ErrMalformedMethod,
+		token:   `reason="no method"`,
+		off:     13,
+		segment: 1,
+	},
+	{
+		value:   "example.com; spf=pass; reason=nxdomain",
+		err:     ErrMalformedMethod,
+		token:   "reason=nxdomain",
+		off:     23,
+		segment: 2,
+	},
+	{
+		value:   "i=1; example.com; spf=pass; dkim/x=pass",
+		err:     ErrMalformedMethod,
+		token:   "dkim/x=pass",
+		off:     28,
+		segment: 3,
+	},
+}
+
+func TestParse_error(t *testing.T) {
+	for _, test := range parseErrorTests {
+		err := Parse(test.value).Error
+		if !errors.Is(err, test.err) {
+			t.Errorf("Parse(%q): expected error %v, got %v", test.value, test.err, err)
+			continue
+		}
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Parse(%q): expected a *ParseError, got %T", test.value, err)
+		} else if parseErr.Token != test.token || parseErr.Offset != test.off || parseErr.Segment != test.segment {
+			t.Errorf("Parse(%q): expected error at %q (offset %v, segment %v), got %q (offset %v, segment %v)", test.value, test.token, test.off, test.segment, parseErr.Token, parseErr.Offset, parseErr.Segment)
+		}
+	}
+}
+
+var parseVersionTests = []struct {
+	value    string
+	version  int
+	explicit bool
+	err      error
+}{
+	{"example.com; none", 1, false, nil},
+	{"example.com 1; none", 1, true, nil},
+	{"i=1; example.com 1; arc=pass", 1, true, nil},
+	{"example.com 2; none", 2, true, ErrUnsupportedVersion},
+	{"example.com 02; none", 2, true, ErrUnsupportedVersion},
+	{"example.com [192.0.2.1] 1; none", 1, true, nil},
+	{"example.com none", 1, false, nil},
+}
+
+var parseStrictEmptyTests = []struct {
+	value string
+	err   error
+}{
+	{"", ErrEmptyInput},
+	{" \r\n ", ErrEmptyInput},
+	{"example.org", ErrEmptyInput},
+	{"example.org 1 (no results)", ErrEmptyInput},
+	{"i=1; example.org", ErrEmptyInput},
+	{"example.org; none", nil},
+	{"example.org none", nil},
+	{"example.org;", nil},
+	{"example.org spf=pass", nil},
+}
+
+func TestParseStrict_empty(t *testing.T) {
+	for _, test := range parseStrictEmptyTests {
+		if _, err := ParseStrict(test.value); !errors.Is(err, test.err) {
+			t.Errorf("ParseStrict(%q) = %v, want %v", test.value, err, test.err)
+		}
+		if p := Parse(test.value); p.Error != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.value, p.Error)
+		}
+	}
+}
+
+func TestParse_version(t *testing.T) {
+	for _, test := range parseVersionTests {
+		p := Parse(test.value)
+		if !errors.Is(p.Error, test.err) {
+			t.Errorf("Parse(%q): expected error %v, got %v", test.value, test.err, p.Error)
+		}
+		if p.Version != test.version || p.ExplicitVersion != test.explicit {
+			t.Errorf("Parse(%q): expected version %v (explicit: %v), got %v (explicit: %v)", test.value, test.version, test.explicit, p.Version, p.ExplicitVersion)
+		}
+	}
+}
+
+func TestParse_resultVersion(t *testing.T) {
+	v := "example.com; dkim=pass; dkim/1=pass; x-custom/2=fail"
+	want := []struct {
+		version  int
+		explicit bool
+	}{{1, false}, {1, true}, {2, true}}
+
+	p := Parse(v)
+	if p.Error != nil || len(p.Results) != len(want) {
+		t.Fatalf("Parse(%q) = %v, %v", v, p.Results, p.Error)
+	}
+	for i, r := range p.Results {
+		if b := r.base(); b.Version != want[i].version || b.ExplicitVersion != want[i].explicit {
+			t.Errorf("Parse(%q): expected version %v (explicit: %v) for result #%v, got %v (explicit: %v)", v, want[i].version, want[i].explicit, i, b.Version, b.ExplicitVersion)
+		}
+	}
+	if s := Format(p.Identifier, p.Results); s != v {
+		t.Errorf("Format(Parse(%q)) = %q", v, s)
+	}
+}
+
+func TestNormalizeMethod(t *testing.T) {
+	tests := []struct {
+		raw     string
+		name    string
+		version int
+	}{
+		{"dkim", "dkim", 0},
+		{" DKIM ", "dkim", 0},
+		{"DKIM/1", "dkim", 1},
+		{"spf / 2", "spf", 2},
+		{"dkim/x", "dkim", -1},
+		{"dkim/0", "dkim", -1},
+	}
+	for _, test := range tests {
+		name, version := normalizeMethod(test.raw)
+		if name != test.name || version != test.version {
+			t.Errorf("normalizeMethod(%q) = %q, %v, want %q, %v", test.raw, name, version, test.name, test.version)
+		}
+	}
+}
+
+func TestGenericResult_PropertiesByType(t *testing.T) {
+	r := Parse("example.com; x-custom=pass reason=ok header.d=example.org dns.sec=yes policy.score=4.5 policy.Level=high").Results[0].(*GenericResult)
+	want := map[PropertyType]map[string]string{
+		PropertyHeader: {"d": "example.org"},
+		PropertyDNS:    {"sec": "yes"},
+		PropertyPolicy: {"score": "4.5", "level": "high"},
+	}
+	if got := r.PropertiesByType(); !reflect.DeepEqual(got, want) {
+		t.Errorf("PropertiesByType() = %v, expected %v", got, want)
+	}
+}
+
+func TestGenericResult_IsExperimental(t *testing.T) {
+	v := "example.com; x-internal-score=pass policy.score=4.5; X-Other=fail; spam=pass"
+	want := []bool{true, true, false}
+
+	p := Parse(v)
+	if p.Error != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", v, p.Error)
+	}
+	if len(p.Results) != len(want) {
+		t.Fatalf("Parse(%q): expected %v results, got %v", v, len(want), len(p.Results))
+	}
+	for i, r := range p.Results {
+		if exp := r.(*GenericResult).IsExperimental(); exp != want[i] {
+			t.Errorf("IsExperimental() for result #%v = %v, want %v", i, exp, want[i])
+		}
+	}
+
+	if s := FormatWithOptions(p.Identifier, p.Results, &FormatOptions{}); !strings.EqualFold(s, v) {
+		t.Errorf("Format(Parse(%q)) = %q", v, s)
+	}
+}
+
+func TestParse_policy(t *testing.T) {
+	v := "example.com; iprev=pass policy.iprev=192.0.2.1;" +
+		" dmarc=fail header.from=example.net policy.DMARC=reject policy.ptype=x;" +
+		" x-custom=pass policy.foo=bar; spf=pass smtp.mailfrom=example.net"
+	want := []map[string]string{
+		{"iprev": "192.0.2.1"},
+		{"dmarc": "reject", "ptype": "x"},
+		{"foo": "bar"},
+		nil,
+	}
+
+	p := Parse(v)
+	if p.Error != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", v, p.Error)
+	}
+	if len(p.Results) != len(want) {
+		t.Fatalf("Parse(%q): expected %v results, got %v", v, len(want), len(p.Results))
+	}
+	for i, r := range p.Results {
+		if policy := r.base().Policy; !reflect.DeepEqual(policy, want[i]) {
+			t.Errorf("Parse(%q): expected policy of result #%v to be %v, got %v", v, i, want[i], policy)
+		}
+	}
+
+	r := &SPFResult{
+		ResultBase: ResultBase{Policy: map[string]string{"spf": "none"}},
+		Value:      ResultPass,
+	}
+	if s, want := formatResult(r), "spf=pass policy.spf=none"; s != want {
+		t.Errorf("formatResult() = %q, want %q", s, want)
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	v := "example.com; spf=pass smtp.mailfrom=example.net; dkim=pass header.d= =bar header.i header.s=brisbane"
+	want := []string{"header.d=", "=bar", "header.i"}
+
+	parsed, err := ParseStrict(v)
+	if !errors.Is(err, ErrMalformedParam) {
+		t.Fatalf("ParseStrict(%q): expected error %v, got %v", v, ErrMalformedParam, err)
+	}
+	for _, tok := range want {
+		if !strings.Contains(err.Error(), strconv.Quote(tok)) {
+			t.Errorf("ParseStrict(%q): expected error to mention %q, got %v", v, tok, err)
+		}
+	}
+	if len(parsed.Results) != 2 {
+		t.Errorf("ParseStrict(%q): expected 2 results, got %v", v, parsed.Results)
+	}
+
+	if parsed := Parse(v); parsed.Error != nil {
+		t.Errorf("Parse(%q): expected no error, got %v", v, parsed.Error)
+	} else if dkim, ok := parsed.Results[1].(*DKIMResult); !ok || dkim.Selector != "brisbane" {
+		t.Errorf("Parse(%q): expected DKIM result with selector, got %#v", v, parsed.Results[1])
+	}
+
+	v = "example.com; spf=pass smtp.mailfrom=example.net"
+	if _, err := ParseStrict(v); err != nil {
+		t.Errorf("ParseStrict(%q): expected no error, got %v", v, err)
+	}
+}
+
+func TestParse_warnings(t *testing.T) {
+	v := "example.com; dkim=pass header.d=a.example header.d=b.example header.s; spf=pass"
+	want := []string{
+		`msgauth: duplicate property "header.d=b.example"`,
+		`msgauth: malformed property "header.s"`,
+	}
+
+	parsed := Parse(v)
+	if parsed.Error != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", v, parsed.Error)
+	}
+	if !reflect.DeepEqual(parsed.Warnings, want) {
+		t.Errorf("Parse(%q): expected warnings %q, got %q", v, want, parsed.Warnings)
+	}
+	if dkim := parsed.Results[0].(*DKIMResult); dkim.Domain != "b.example" {
+		t.Errorf("Parse(%q): expected the last header.d to be kept, got %q", v, dkim.Domain)
+	}
+
+	v = "example.com; dkim=pass header.d=a.example header.d=b.example"
+	if _, err := ParseStrict(v); err != nil {
+		t.Errorf("ParseStrict(%q): unexpected error: %v", v, err)
+	}
+	if parsed := Parse("example.com; spf=pass smtp.mailfrom=example.net"); parsed.Warnings != nil {
+		t.Errorf("Parse(): expected no warnings, got %q", parsed.Warnings)
+	}
+
+	v = `example.com; dkim=pass header.i="foo; spf=pass`
+	want = []string{`msgauth: unterminated quoted string "\"foo"`}
+	if parsed := Parse(v); !reflect.DeepEqual(parsed.Warnings, want) {
+		t.Errorf("Parse(%q): expected warnings %q, got %q", v, want, parsed.Warnings)
+	}
+	_, err := ParseStrict(v)
+	var parseErr *ParseError
+	if !errors.Is(err, ErrUnterminatedQuote) || !errors.As(err, &parseErr) {
+		t.Errorf("ParseStrict(%q): expected error %v, got %v", v, ErrUnterminatedQuote, err)
+	} else if parseErr.Token != `"foo` || parseErr.Offset != 32 || parseErr.Segment != 1 {
+		t.Errorf("ParseStrict(%q): expected error at %q (offset 32, segment 1), got %q (offset %v, segment %v)", v, `"foo`, parseErr.Token, parseErr.Offset, parseErr.Segment)
+	}
+}
+
+func TestParse_controlChars(t *testing.T) {
+	for _, reason := range []string{"bad\x00key", "bad\rkey", "bad\x7fkey"} {
+		v := "example.com; dkim=fail reason=\"" + reason + "\" header.d=example.org"
+
+		parsed := Parse(v)
+		if parsed.Error != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", v, parsed.Error)
+		} else if r := parsed.Results[0].(*DKIMResult); r.Reason != "bad key" || r.Domain != "example.org" {
+			t.Errorf("Parse(%q): expected reason %q, got %q", v, "bad key", r.Reason)
+		}
+		if len(parsed.Warnings) != 1 {
+			t.Errorf("Parse(%q): expected a warning, got %q", v, parsed.Warnings)
+		}
+
+		if _, err := ParseStrict(v); !errors.Is(err, ErrControlChar) {
+			t.Errorf("ParseStrict(%q): expected error %v, got %v", v, ErrControlChar, err)
+		}
+	}
+
+	v := "example.com; dkim=fail reason=\"bad\tkey\""
+	if _, err := ParseStrict(v); err != nil {
+		t.Errorf("ParseStrict(%q): unexpected error: %v", v, err)
+	}
+}
+
+func TestParse_emptyValue(t *testing.T) {
+	v := "example.com; dkim= header.d=example.com"
+	want := []Result{&DKIMResult{Value: ResultNone, Domain: "example.com"}}
+
+	parsed := Parse(v)
+	if parsed.Error != nil {
+		t.Errorf("Parse(%q): unexpected error: %v", v, parsed.Error)
+	} else if !reflect.DeepEqual(clearRaw(parsed.Results), want) {
+		t.Errorf("Parse(%q): expected results \n%v\n but got \n%v", v, want, parsed.Results)
+	}
+
+	if _, err := ParseStrict(v); !errors.Is(err, ErrEmptyValue) {
+		t.Errorf("ParseStrict(%q): expected error %v, got %v", v, ErrEmptyValue, err)
+	}
+}
+
+var parseLenientTests = []msgauthTest{
+	{
+		value:      "mx.example.com; dkim=pass header.d=example.org, spf=pass smtp.mailfrom=example.net",
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultPass, Domain: "example.org"},
+			&SPFResult{Value: ResultPass, From: "example.net"},
+		},
+	},
+	{
+		value:      "mx.example.com; dkim=pass,spf=fail; dmarc=pass reason=\"a, b\", iprev=pass",
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{Value: ResultPass},
+			&SPFResult{Value: ResultFail},
+			&DMARCResult{Value: ResultPass, Reason: "a, b"},
+			&IPRevResult{Value: ResultPass},
+		},
+	},
+	{
+		value:      "mx.example.com; x-custom=pass header.list=a,b,c",
+		identifier: "mx.example.com",
+		results: []Result{
+			&GenericResult{
+				Method:     "x-custom",
+				Value:      ResultPass,
+				Params:     map[string]string{"header.list": "a,b,c"},
+				Properties: []Property{{PropertyHeader, "list", "a,b,c"}},
+			},
+		},
+	},
+}
+
+func TestParseLenient(t *testing.T) {
+	for _, test := range parseLenientTests {
+		parsed, err := ParseLenient(test.value)
+		if err != nil {
+			t.Errorf("ParseLenient(%q): unexpected error: %v", test.value, err)
+			continue
+		}
+		if parsed.Identifier != test.identifier {
+			t.Errorf("ParseLenient(%q): expected identifier %q, got %q", test.value, test.identifier, parsed.Identifier)
+		}
+		if !reflect.DeepEqual(test.results, clearRaw(parsed.Results)) {
+			t.Errorf("ParseLenient(%q): expected results \n%v\n but got \n%v", test.value, test.results, parsed.Results)
+		}
+	}
+
+	v := parseLenientTests[0].value
+	if parsed := Parse(v); len(parsed.Results) != 1 {
+		t.Errorf("Parse(%q): expected a single result, got %v", v, parsed.Results)
+	}
+}
+
+func TestParseKnownOnly(t *testing.T) {
+	v := "example.com; x-custom=pass; spf=pass smtp.mailfrom=example.net; Vendor-Check=fail"
+	parsed, err := ParseKnownOnly(v)
+	if err != nil {
+		t.Fatalf("ParseKnownOnly(%q): unexpected error: %v", v, err)
+	}
+	if len(parsed.Results) != 1 {
+		t.Errorf("ParseKnownOnly(%q): expected a single result, got %v", v, parsed.Results)
+	} else if _, ok := parsed.Results[0].(*SPFResult); !ok {
+		t.Errorf("ParseKnownOnly(%q): expected an SPF result, got %T", v, parsed.Results[0])
+	}
+	if want := []string{"x-custom", "vendor-check"}; !reflect.DeepEqual(parsed.Unknown, want) {
+		t.Errorf("ParseKnownOnly(%q): expected unknown methods %v, got %v", v, want, parsed.Unknown)
+	}
+
+	if parsed := Parse(v); len(parsed.Results) != 3 || parsed.Unknown != nil {
+		t.Errorf("Parse(%q): expected 3 results and no unknown methods, got %v and %v", v, parsed.Results, parsed.Unknown)
+	}
+}
+
+func TestParseWithLimit(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("example.com")
+	for i := 0; i < 10000; i++ {
+		sb.WriteString("; x-bogus=pass")
+	}
+	v := sb.String()
+
+	parsed, err := ParseWithLimit(v, 100)
+	if !errors.Is(err, ErrTooManyResults) {
+		t.Errorf("ParseWithLimit(): expected error %v, got %v", ErrTooManyResults, err)
+	}
+	if len(parsed.Results) != 100 {
+		t.Errorf("ParseWithLimit(): expected 100 results, got %v", len(parsed.Results))
+	}
+
+	v = "example.com; spf=pass smtp.mailfrom=example.net; dkim=pass header.d=example.net"
+	if parsed, err := ParseWithLimit(v, 2); err != nil || len(parsed.Results) != 2 {
+		t.Errorf("ParseWithLimit(%q): expected 2 results, got %v and %v", v, parsed.Results, err)
+	}
+	if parsed, err := ParseWithLimit(v, 0); err != nil || len(parsed.Results) != 2 {
+		t.Errorf("ParseWithLimit(%q): expected no limit, got %v and %v", v, parsed.Results, err)
+	}
+}
+
+func TestParser(t *testing.T) {
+	v := "example.com; spf=pass (sender verified) smtp.mailfrom=example.net; dkim=pass header.d=example.net; x-custom=pass"
+
+	p := &Parser{
+		KnownOnly:       true,
+		Methods:         map[string]bool{"spf": true},
+		DiscardComments: true,
+	}
+	parsed, err := p.Parse(v)
+	if err != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", v, err)
+	}
+	want := []Result{&SPFResult{Value: ResultPass, From: "example.net"}}
+	if !reflect.DeepEqual(clearRaw(parsed.Results), want) {
+		t.Errorf("Parse(%q): expected results \n%v\n but got \n%v", v, want, parsed.Results)
+	}
+	if want := []string{"dkim", "x-custom"}; !reflect.DeepEqual(parsed.Unknown, want) {
+		t.Errorf("Parse(%q): expected unknown methods %v, got %v", v, want, parsed.Unknown)
+	}
+	if parsed.Comments != nil {
+		t.Errorf("Parse(%q): expected no comments, got %q", v, parsed.Comments)
+	}
+
+	p = &Parser{Strict: true, MaxResults: 2}
+	if _, err := p.Parse(v); !errors.Is(err, ErrTooManyResults) {
+		t.Errorf("Parse(%q): expected error %v, got %v", v, ErrTooManyResults, err)
+	}
+
+	var zero Parser
+	if parsed, err := zero.Parse(v); err != nil || len(parsed.Results) != 3 {
+		t.Errorf("Parse(%q): expected 3 results, got %v and %v", v, parsed.Results, err)
+	}
+}
+
+func TestParseMultiple(t *testing.T) {
+	values := []string{
+		"mx.example.com; spf=pass smtp.mailfrom=example.net",
+		"mx.example.com 2; none",
+		"relay.example.org; dkim=pass header.d=example.net",
+	}
+
+	parsed, err := ParseMultiple(values)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected error %v, got %v", ErrUnsupportedVersion, err)
+	}
+	if len(parsed) != len(values) {
+		t.Fatalf("Expected %v parsed header fields, got %v", len(values), len(parsed))
+	}
+	if parsed[0].Error != nil || parsed[2].Error != nil {
+		t.Errorf("Expected no error for valid header fields, got %v and %v", parsed[0].Error, parsed[2].Error)
+	}
+	if !errors.Is(parsed[1].Error, ErrUnsupportedVersion) {
+		t.Errorf("Expected error %v for malformed header field, got %v", ErrUnsupportedVersion, parsed[1].Error)
+	}
+	if parsed[2].Identifier != "relay.example.org" || len(parsed[2].Results) != 1 {
+		t.Errorf("Expected one result from relay.example.org, got %v from %q", parsed[2].Results, parsed[2].Identifier)
+	}
+
+	filtered := FilterByIdentifier(parsed, "MX.example.com")
+	if len(filtered) != 1 || filtered[0] != parsed[0] {
+		t.Errorf("Expected FilterByIdentifier to return the first header field, got %v", filtered)
+	}
+}
+
+func TestRemoveByIdentifier(t *testing.T) {
+	values := []string{
+		"mx.example.com; spf=pass smtp.mailfrom=example.net",
+		" relay.example.org;\r\n\tdkim=pass  header.d=example.net",
+		"MX.EXAMPLE.COM (forged); dkim=pass header.d=example.net",
+		"i=1; mx.example.com; arc=none",
+		"garbage",
+		"mx.example.com 2; dkim=pass header.d=example.net",
+		"i=x; mx.example.com (forged); dkim=pass header.d=example.net",
+		"mx.example.com; dkim==pass",
+		"mx.example.com.;",
+	}
+	want := []string{values[1], values[4]}
+	if l := RemoveByIdentifier(values, "mx.example.com"); !reflect.DeepEqual(l, want) {
+		t.Errorf("RemoveByIdentifier() = %q, want %q", l, want)
+	}
+}
+
+var dmarcFromDomainTests = []struct {
+	from   string
+	domain string
+}{
+	{"example.org", "example.org"},
+	{"Example.ORG.", "example.org"},
+	{"user@example.org", "example.org"},
+	{"John Doe <john@mail.example.org>", "mail.example.org"},
+	{"\"Doe, John\"\r\n <john@example.org>", "example.org"},
+	{"", ""},
+	{"<unterminated@example.org", ""},
+	{"not a domain", ""},
+}
+
+func TestDMARCResult_FromDomain(t *testing.T) {
+	for _, test := range dmarcFromDomainTests {
+		r := &DMARCResult{From: test.from}
+		if domain := r.FromDomain(); domain != test.domain {
+			t.Errorf("FromDomain() with header.from=%q = %q, expected %q", test.from, domain, test.domain)
+		}
+	}
+}
+
+func TestDMARCResult_IsAligned(t *testing.T) {
+	tests := []struct {
+		v       string
+		aligned bool
+	}{
+		{"example.com; dmarc=pass header.from=example.com", false},
+		{"example.com; dmarc=pass header.from=example.com policy.alignment=pass", true},
+		{"example.com; dmarc=pass header.from=example.com policy.alignment=Aligned", true},
+		{"example.com; dmarc=pass header.from=example.com policy.alignment=Relaxed", false},
+		{"example.com; dmarc=fail header.from=example.com policy.alignment=strict", false},
+		{"example.com; dmarc=fail header.from=example.com policy.alignment=fail", false},
+		{"example.com; dmarc=pass header.from=mail.example.com policy.alignment=example.com", true},
+		{"example.com; dmarc=fail header.from=example.com policy.alignment=example.net", false},
+	}
+	for _, test := range tests {
+		r := Parse(test.v).Results[0].(*DMARCResult)
+		if aligned := r.IsAligned(); aligned != test.aligned {
+			t.Errorf("Parse(%q): IsAligned() = %v, expected %v", test.v, aligned, test.aligned)
+		}
+	}
+}
+
+func TestResultValue_Severity(t *testing.T) {
+	order := [][]ResultValue{
+		{ResultPass},
+		{ResultNone},
+		{ResultNeutral, "x-unknown"},
+		{ResultPolicy},
+		{ResultSoftFail},
+		{ResultFail, ResultHardFail, "FAIL"},
+		{ResultTempError},
+		{ResultPermError},
+	}
+	for i, l := range order {
+		for _, v := range l {
+			if got, want := v.Severity(), l[0].Severity(); got != want {
+				t.Errorf("%q.Severity() = %v, expected %v", v, got, want)
+			}
+			if i > 0 && v.Severity() <= order[i-1][0].Severity() {
+				t.Errorf("%q.Severity() = %v, expected more than %q", v, v.Severity(), order[i-1][0])
+			}
+		}
+	}
+}
+
+func TestDMARCResult_Override(t *testing.T) {
+	tests := []struct {
+		v        string
+		override string
+		format   string
+	}{
+		{
+			v:        "example.com; dmarc=fail policy.override=Forwarded header.from=example.org",
+			override: "forwarded",
+			format:   "dmarc=fail header.from=example.org policy.override=forwarded",
+		},
+		{
+			v:        `example.com; dmarc=fail reason="sampled_out" header.from=example.org`,
+			override: "sampled_out",
+			format:   "dmarc=fail reason=sampled_out header.from=example.org",
+		},
+		{
+			v:        `example.com; dmarc=fail reason="p=reject override=mailing_list" policy.override=other`,
+			override: "other",
+			format:   `dmarc=fail reason="p=reject override=mailing_list" policy.override=other`,
+		},
+		{
+			v:      `example.com; dmarc=fail reason="other reasons"`,
+			format: `dmarc=fail reason="other reasons"`,
+		},
+		{
+			v:        `example.com; dmarc=fail reason=forwarded policy.override=forwarded`,
+			override: "forwarded",
+			format:   `dmarc=fail reason=forwarded policy.override=forwarded`,
+		},
+		{
+			v:      `example.com; dmarc=fail reason="dis=reject" policy.dmarc=reject`,
+			format: `dmarc=fail reason="dis=reject" policy.dmarc=reject`,
+		},
+		{
+			v:      `example.com; dmarc=fail reason="message was not forwarded"`,
+			format: `dmarc=fail reason="message was not forwarded"`,
+		},
+		{
+			v:      `example.com; dmarc=fail reason="not a mailing_list"`,
+			format: `dmarc=fail reason="not a mailing_list"`,
+		},
+		{
+			v:        `example.com; dmarc=fail reason="dis=none override=trusted_forwarder"`,
+			override: "trusted_forwarder",
+			format:   `dmarc=fail reason="dis=none override=trusted_forwarder"`,
+		},
+	}
+	for _, test := range tests {
+		r := Parse(test.v).Results[0].(*DMARCResult)
+		if r.Override != test.override {
+			t.Errorf("Parse(%q): expected override %q, got %q", test.v, test.override, r.Override)
+		}
+		if s := r.String(); s != test.format {
+			t.Errorf("String() = %q, expected %q", s, test.format)
+		}
+	}
+}
+
+var authenticatedTests = []struct {
+	r interface {
+		Authenticated() bool
+		Rejectable() bool
+	}
+	authenticated bool
+	rejectable    bool
+}{
+	{&SPFResult{Value: ResultPass}, true, false},
+	{&SPFResult{Value: ResultFail}, false, true},
+	{&SPFResult{Value: ResultHardFail}, false, true},
+	{&SPFResult{Value: ResultSoftFail}, false, false},
+	{&SPFResult{Value: ResultNone}, false, false},
+	{&DKIMResult{Value: ResultPass}, true, false},
+	{&DKIMResult{Value: ResultFail}, false, true},
+	{&DKIMResult{Value: ResultTempError}, false, false},
+	{&DMARCResult{Value: ResultPass}, true, false},
+	{&DMARCResult{Value: ResultFail}, false, true},
+	{&DMARCResult{Value: ResultNone}, false, false},
+}
+
+func TestResult_Authenticated(t *testing.T) {
+	for _, test := range authenticatedTests {
+		if v := test.r.Authenticated(); v != test.authenticated {
+			t.Errorf("Authenticated() for %v = %v, want %v", test.r, v, test.authenticated)
+		}
+		if v := test.r.Rejectable(); v != test.rejectable {
+			t.Errorf("Rejectable() for %v = %v, want %v", test.r, v, test.rejectable)
+		}
+	}
+}
+
+var spfMailFromTests = []struct {
+	from    string
+	local   string
+	domain  string
+	address string
+}{
+	{"sender@example.net", "sender", "example.net", "sender@example.net"},
+	{"<Sender@Example.NET>", "Sender", "example.net", "Sender@Example.NET"},
+	{" <sender@example.net> ", "sender", "example.net", "sender@example.net"},
+	{"example.net", "", "example.net", "example.net"},
+	{"<>", "", "", ""},
+	{"", "", "", ""},
+	{`"john@home"@example.net`, `"john@home"`, "example.net", `"john@home"@example.net`},
+	{`<"john \"at\" home"@Example.NET>`, `"john \"at\" home"`, "example.net", `"john \"at\" home"@Example.NET`},
+	{`"john@home"`, "", `"john@home"`, `"john@home"`},
+	{"<@relay.example.org:john@example.net>", "john", "example.net", "john@example.net"},
+}
+
+func TestSPFResult_MailFrom(t *testing.T) {
+	for _, test := range spfMailFromTests {
+		r := &SPFResult{From: test.from}
+		if local := r.MailFromLocalPart(); local != test.local {
+			t.Errorf("MailFromLocalPart() with smtp.mailfrom=%q = %q, expected %q", test.from, local, test.local)
+		}
+		if domain := r.MailFromDomain(); domain != test.domain {
+			t.Errorf("MailFromDomain() with smtp.mailfrom=%q = %q, expected %q", test.from, domain, test.domain)
+		}
+		if address := r.MailFromAddress(); address != test.address {
+			t.Errorf("MailFromAddress() with smtp.mailfrom=%q = %q, expected %q", test.from, address, test.address)
+		}
+	}
+}
+
+func TestSPFResult_quotedMailFrom(t *testing.T) {
+	v := `example.com; spf=pass smtp.mailfrom="john@home; (x)"@example.net`
+	p := Parse(v)
+	if p.Error != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", v, p.Error)
+	}
+	r := p.Results[0].(*SPFResult)
+	if want := `"john@home; (x)"@example.net`; r.From != want {
+		t.Errorf("Parse(%q): expected smtp.mailfrom %q, got %q", v, want, r.From)
+	}
+	if s := FormatWithOptions(p.Identifier, p.Results, &FormatOptions{}); s != v {
+		t.Errorf("Format() = %q, expected %q", s, v)
+	}
+}
+
+func TestSenderIDResult_HeaderKey(t *testing.T) {
+	v := "example.com; sender-id=pass header.from=a.example header.sender=list@b.example"
+	r := Parse(v).Results[0].(*SenderIDResult)
+	r.HeaderKey, r.HeaderValue = "sender", "owner@c.example"
+
+	want := "sender-id=pass header.sender=owner@c.example"
+	if s := r.String(); s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+
+	r.HeaderKey, r.HeaderValue = "from", "d.example"
+	want = "sender-id=pass header.from=d.example header.sender=list@b.example"
+	if s := r.String(); s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+}
+
+func TestSPFResult_Ehlo(t *testing.T) {
+	tests := []struct {
+		v    string
+		want *SPFResult
+	}{
+		{
+			v:    "example.com; spf=pass smtp.helo=mail.example.net",
+			want: &SPFResult{Value: ResultPass, Helo: "mail.example.net"},
+		},
+		{
+			v:    "example.com; spf=pass smtp.ehlo=mail.example.net",
+			want: &SPFResult{Value: ResultPass, Helo: "mail.example.net", Ehlo: true},
+		},
+		{
+			v: "example.com; spf=pass smtp.ehlo=mx.example.net smtp.helo=mail.example.net",
+			want: &SPFResult{
+				ResultBase: ResultBase{Extra: map[string]string{"smtp.ehlo": "mx.example.net"}},
+				Value:      ResultPass,
+				Helo:       "mail.example.net",
+			},
+		},
+	}
+	for _, test := range tests {
+		p := Parse(test.v)
+		if !reflect.DeepEqual(clearRaw(p.Results), []Result{test.want}) {
+			t.Errorf("Parse(%q) = %#v, expected %#v", test.v, p.Results[0], test.want)
+		}
+		if s := FormatWithOptions(p.Identifier, p.Results, &FormatOptions{}); s != test.v {
+			t.Errorf("Format() = %q, expected %q", s, test.v)
+		}
+	}
+}
+
+var spfHeloIsValidTests = []struct {
+	helo  string
+	valid bool
+}{
+	{"mail.example.org", true},
+	{"MX-1.Example.ORG", true},
+	{"localhost", true},
+	{"[192.0.2.1]", true},
+	{"[IPv6:2001:db8::1]", true},
+	{"", false},
+	{"mail.example.org.", false},
+	{"-mail.example.org", false},
+	{"mail_1.example.org", false},
+	{"mail..example.org", false},
+	{"not a hostname", false},
+	{"192.0.2.1]", false},
+	{"[192.0.2.256]", false},
+	{"[2001:db8::1]", false},
+	{"[IPv6:192.0.2.1]", false},
+}
+
+func TestSPFResult_HeloIsValid(t *testing.T) {
+	for _, test := range spfHeloIsValidTests {
+		r := &SPFResult{Helo: test.helo}
+		if valid := r.HeloIsValid(); valid != test.valid {
+			t.Errorf("HeloIsValid() with smtp.helo=%q = %v, expected %v", test.helo, valid, test.valid)
+		}
+	}
+}
+
+var dkimSignaturePrefixTests = []struct {
+	signature string
+	prefix    []byte
+	err       bool
+}{
+	{"AbCdEfGh", []byte{0x01, 0xb0, 0x9d, 0x11, 0xf1, 0xa1}, false},
+	{"AbCdEf", []byte{0x01, 0xb0, 0x9d, 0x11}, false},
+	{"AbCdEfG", []byte{0x01, 0xb0, 0x9d, 0x11, 0xf1}, false},
+	{"AbCdE", []byte{0x01, 0xb0, 0x9d}, false},
+	{"AQ==", []byte{0x01}, false},
+	{"", nil, false},
+	{"not base64!", nil, true},
+}
+
+func TestDKIMResult_SignaturePrefix(t *testing.T) {
+	for _, test := range dkimSignaturePrefixTests {
+		r := &DKIMResult{Signature: test.signature}
+		prefix, err := r.SignaturePrefix()
+		if (err != nil) != test.err {
+			t.Errorf("SignaturePrefix() with header.b=%q: unexpected error %v", test.signature, err)
+		} else if !test.err && !bytes.Equal(prefix, test.prefix) {
+			t.Errorf("SignaturePrefix() with header.b=%q = %x, want %x", test.signature, prefix, test.prefix)
+		}
+	}
+}
+
+var timestampTests = []struct {
+	value string
+	want  time.Time
+}{
+	{"dkim=pass header.d=example.org header.t=1700000000", time.Unix(1700000000, 0)},
+	{"dkim=pass header.d=example.org x-timestamp=2023-11-14T22:13:20Z", time.Unix(1700000000, 0)},
+	{"arc=pass header.i=1 policy.x-timestamp=1700000000", time.Unix(1700000000, 0)},
+	{"arc=pass header.i=1 header.t=2023-11-14T23:13:20+01:00", time.Unix(1700000000, 0)},
+	{"dkim=pass header.d=example.org", time.Time{}},
+	{"dkim=pass header.t=yesterday", time.Time{}},
+}
+
+func TestARCResult_Chain(t *testing.T) {
+	v := "example.com; arc=pass header.i=2 arc.chain=\"example.com, example.net,\" arc.oldest-pass=1"
+	want := &ARCResult{Value: ResultPass, Instance: 2, OldestPass: 1, Chain: []string{"example.com", "example.net"}}
+
+	r := Parse(v).Results[0]
+	if !reflect.DeepEqual(clearRaw([]Result{r}), []Result{want}) {
+		t.Fatalf("Parse(%q) = %#v, expected %#v", v, r, want)
+	}
+	s := r.(*ARCResult).String()
+	if wantStr := "arc=pass arc.chain=example.com,example.net arc.oldest-pass=1 header.i=2"; s != wantStr {
+		t.Errorf("String() = %q, expected %q", s, wantStr)
+	}
+
+	v = "example.com; arc=pass arc.chain=, arc.oldest-pass=x"
+	if r := Parse(v).Results[0].(*ARCResult); r.Chain != nil || r.OldestPass != 0 {
+		t.Errorf("Parse(%q): expected invalid values to be ignored, got %#v", v, r)
+	}
+}
+
+func TestResult_Timestamp(t *testing.T) {
+	for _, test := range timestampTests {
+		p := Parse("example.com; " + test.value)
+		if len(p.Results) != 1 {
+			t.Fatalf("Parse(%q): expected a single result, got %v", test.value, p.Results)
+		}
+
+		var ts time.Time
+		switch r := p.Results[0].(type) {
+		case *DKIMResult:
+			ts = r.Timestamp()
+		case *ARCResult:
+			ts = r.Timestamp()
+		}
+		if !ts.Equal(test.want) {
+			t.Errorf("Timestamp() for %q = %v, want %v", test.value, ts, test.want)
+		}
+	}
+}
+
+var iprevNormalizedIPTests = []struct {
+	ip   string
+	want net.IP
+}{
+	{"192.0.2.1", net.IPv4(192, 0, 2, 1).To4()},
+	{"[192.0.2.1]", net.IPv4(192, 0, 2, 1).To4()},
+	{"2001:db8::1", net.ParseIP("2001:db8::1")},
+	{"2001:0DB8:0000:0000:0000:0000:0000:0001", net.ParseIP("2001:db8::1")},
+	{"[2001:db8::1]", net.ParseIP("2001:db8::1")},
+	{"[IPv6:2001:db8::1]", net.ParseIP("2001:db8::1")},
+	{"fe80::1%eth0", net.ParseIP("fe80::1")},
+	{"[IPv6:fe80::1%25eth0]", net.ParseIP("fe80::1")},
+	{"192.0.2.1%eth0", nil},
+	{"mail.example.org", nil},
+	{"", nil},
+}
+
+func TestIPRevResult_NormalizedIP(t *testing.T) {
+	for _, test := range iprevNormalizedIPTests {
+		r := &IPRevResult{IP: test.ip}
+		if ip := r.NormalizedIP(); !ip.Equal(test.want) || len(ip) != len(test.want) {
+			t.Errorf("NormalizedIP() with policy.iprev=%q = %v, expected %v", test.ip, ip, test.want)
+		}
+	}
+}
+
+func TestIPRevResult_IPMatches(t *testing.T) {
+	tests := []struct {
+		ip      string
+		other   net.IP
+		matches bool
+	}{
+		{"192.0.2.1", net.IPv4(192, 0, 2, 1), true},
+		{"192.0.2.1", net.IPv4(192, 0, 2, 2), false},
+		{"fe80::1%eth0", net.ParseIP("fe80::1"), true},
+		{"[IPv6:2001:db8::1]", net.ParseIP("2001:db8::1"), true},
+		{"", nil, false},
+		{"mail.example.org", net.IPv4(192, 0, 2, 1), false},
+	}
+	for _, test := range tests {
+		r := &IPRevResult{IP: test.ip}
+		if matches := r.IPMatches(test.other); matches != test.matches {
+			t.Errorf("IPMatches(%v) with policy.iprev=%q = %v, expected %v", test.other, test.ip, matches, test.matches)
+		}
+	}
+}
+
+var parseInstanceTests = []struct {
+	value      string
+	instance   int
+	identifier string
+	results    []Result
+}{
+	{
+		value:      "i=3; mx.example.com; dkim=pass header.d=example.org",
+		instance:   3,
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{ARCInstance: 3}, Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value:      "i=3; mx.example.com 1; dkim=pass header.d=example.org",
+		instance:   3,
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{ARCInstance: 3}, Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value:      "i=3;mx.example.com  1 ;dkim=pass header.d=example.org",
+		instance:   3,
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{ARCInstance: 3}, Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value:      "i=3; mx.example.com 1 dkim=pass header.d=example.org",
+		instance:   3,
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{ARCInstance: 3}, Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value:      "i= 2; mx.example.com; dkim=pass header.d=example.org",
+		instance:   2,
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{ARCInstance: 2}, Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value:      "i=2 (second hop); mx.example.com; dkim=pass header.d=example.org",
+		instance:   2,
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{ARCInstance: 2}, Value: ResultPass, Domain: "example.org"},
+		},
+	},
+	{
+		value:      "i =(hop)\r\n 2; mx.example.com; dkim=pass header.d=example.org",
+		instance:   2,
+		identifier: "mx.example.com",
+		results: []Result{
+			&DKIMResult{ResultBase: ResultBase{ARCInstance: 2}, Value: ResultPass, Domain: "example.org"},
+		},
+	},
+}
+
+func TestParse_instance(t *testing.T) {
+	for _, test := range parseInstanceTests {
+		p := Parse(test.value)
+		if p.Error != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.value, p.Error)
+			continue
+		}
+		if p.Instance != test.instance {
+			t.Errorf("Parse(%q): expected instance %v, got %v", test.value, test.instance, p.Instance)
+		}
+		if p.Identifier != test.identifier {
+			t.Errorf("Parse(%q): expected identifier %q, got %q", test.value, test.identifier, p.Identifier)
+		}
+		if !reflect.DeepEqual(test.results, clearRaw(p.Results)) {
+			t.Errorf("Parse(%q): expected results \n%v\n but got \n%v", test.value, test.results, p.Results)
+		}
+	}
+}
+
+func TestParse_truncatedInstance(t *testing.T) {
+	for _, v := range []string{"i=1", " i=2 "} {
+		p := Parse(v)
+		if !errors.Is(p.Error, ErrMissingIdentifier) {
+			t.Errorf("Parse(%q): expected error %v, got %v", v, ErrMissingIdentifier, p.Error)
+		}
+		if p.Identifier != "" || p.Results != nil {
+			t.Errorf("Parse(%q): expected no identifier and no results, got %q and %v", v, p.Identifier, p.Results)
+		}
+	}
+}
+
+var parseNoneTests = []struct {
+	value    string
+	none     bool
+	comments []string
+}{
+	{"example.org; none", true, nil},
+	{"example.org 1; NONE", true, nil},
+	{"example.org; none (no checks performed)", true, []string{"no checks performed"}},
+	{"mx.example.com; (no checks; performed) none", true, []string{"no checks; performed"}},
+	{"mx.example.com (forwarder);\r\n none\r\n (no checks\r\n performed)", true, []string{"forwarder", "no checks performed"}},
+	{"example.org;", false, nil},
+	{"example.org", false, nil},
+	{"example.org; spf=none smtp.mailfrom=example.net", false, nil},
+}
+
+func TestParse_none(t *testing.T) {
+	for _, test := range parseNoneTests {
+		p := Parse(test.value)
+		if p.Error != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.value, p.Error)
+			continue
+		}
+		if p.None != test.none {
+			t.Errorf("Parse(%q): expected None to be %v, got %v", test.value, test.none, p.None)
+		}
+		if test.none && len(p.Results) != 0 {
+			t.Errorf("Parse(%q): expected no results, got %v", test.value, p.Results)
+		}
+		if !reflect.DeepEqual(p.Comments, test.comments) {
+			t.Errorf("Parse(%q): expected comments %q, got %q", test.value, test.comments, p.Comments)
+		}
+	}
+}
+
+func BenchmarkParseSPFOnly(b *testing.B) {
+	const v = "mx.example.com; spf=pass smtp.mailfrom=sender@example.net"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Parse(v)
+	}
+}
+
+func BenchmarkParseMultiMethod(b *testing.B) {
+	const v = "mx.example.com;\r\n" +
+		"\tdkim=pass header.i=@example.net header.s=selector header.b=AbCdEfGh;\r\n" +
+		"\tspf=pass (domain of sender@example.net designates 192.0.2.1 as permitted sender) smtp.mailfrom=sender@example.net;\r\n" +
+		"\tdmarc=pass (p=none sp=none dis=none) header.from=example.net"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Parse(v)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, test := range msgauthTests {
+		f.Add(test.value)
+	}
+	f.Add("i=1; mx.example.com; arc=pass (i=1 spf=pass) header.i=1")
+	f.Add("mx.example.com 1; dkim=pass reason=\"a \\\"b\\\"\" header.d=(comment)example.org")
+	f.Add("mx.example.com;; dkim/1=pass,spf=pass;;")
+	f.Add("i=1")
+
+	f.Fuzz(func(t *testing.T, v string) {
+		p := Parse(v)
+		b, err := p.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText() = %v", err)
+		}
+		Parse(string(b))
+	})
+}
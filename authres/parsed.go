@@ -0,0 +1,433 @@
+package authres
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// First returns the first result of type T.
+//
+//	spf, ok := authres.First[*authres.SPFResult](parsed)
+func First[T Result](p *Parsed) (T, bool) {
+	for _, r := range p.Results {
+		if r, ok := r.(T); ok {
+			return r, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// All returns all results of type T.
+func All[T Result](p *Parsed) []T {
+	var l []T
+	for _, r := range p.Results {
+		if r, ok := r.(T); ok {
+			l = append(l, r)
+		}
+	}
+	return l
+}
+
+// The Reasons methods of results return their reason, followed by the values
+// of the other reason-like params, e.g. "x-reason" or "policy.reason", sorted
+// by key. Empty reasons are omitted.
+
+func (r *AuthResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *DKIMResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *DomainKeysResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *IPRevResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *SenderIDResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *SPFResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *DMARCResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *DKIMADSPResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *BIMIResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *ARCResult) Reasons() []string {
+	return reasons(r)
+}
+
+func (r *GenericResult) Reasons() []string {
+	return reasons(r)
+}
+
+func reasons(r Result) []string {
+	_, params := resultParams(r)
+
+	var l []string
+	if reason := params["reason"]; reason != "" {
+		l = append(l, reason)
+	}
+
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k != "reason" && v != "" && strings.HasSuffix(strings.ToLower(k), "reason") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		l = append(l, params[k])
+	}
+	return l
+}
+
+// ByMethod returns the results grouped by lowercase method name, e.g. "dkim".
+// Results are kept in order of appearance.
+func (p *Parsed) ByMethod() map[string][]Result {
+	m := make(map[string][]Result)
+	for _, r := range p.Results {
+		method := resultMethod(r)
+		m[method] = append(m[method], r)
+	}
+	return m
+}
+
+// Len returns the number of results.
+func (p *Parsed) Len() int {
+	return len(p.Results)
+}
+
+// Empty reports whether the header field was parsed successfully but doesn't
+// contain any result, e.g. "example.org; none".
+func (p *Parsed) Empty() bool {
+	return len(p.Results) == 0 && p.Error == nil
+}
+
+// Has reports whether p contains a result of method. Methods are compared
+// case-insensitively.
+func (p *Parsed) Has(method string) bool {
+	for _, r := range p.Results {
+		if strings.EqualFold(resultMethod(r), method) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllPass reports whether each of methods has at least one result, and all of
+// its results pass. If no method is provided, SPF, DKIM and DMARC are checked.
+// Methods are compared case-insensitively.
+func (p *Parsed) AllPass(methods ...string) bool {
+	if len(methods) == 0 {
+		methods = []string{"spf", "dkim", "dmarc"}
+	}
+	for _, method := range methods {
+		found := false
+		for _, r := range p.Results {
+			if !strings.EqualFold(resultMethod(r), method) {
+				continue
+			}
+			if value, _ := r.format(); value != ResultPass {
+				return false
+			}
+			found = true
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Conflicts returns the distinct values of the methods with more than one
+// value, e.g. "dkim" if a DKIM signature passed and another one failed.
+// Methods are lowercase and values are in order of appearance. nil is
+// returned if there is no conflict.
+func (p *Parsed) Conflicts() map[string][]ResultValue {
+	values := make(map[string][]ResultValue)
+	for _, r := range p.Results {
+		method := resultMethod(r)
+		value, _ := r.format()
+		if !containsValue(values[method], value) {
+			values[method] = append(values[method], value)
+		}
+	}
+
+	var m map[string][]ResultValue
+	for method, l := range values {
+		if len(l) < 2 {
+			continue
+		}
+		if m == nil {
+			m = make(map[string][]ResultValue)
+		}
+		m[method] = l
+	}
+	return m
+}
+
+func containsValue(l []ResultValue, v ResultValue) bool {
+	for _, lv := range l {
+		if lv == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Equal reports whether p and other have the same identifier, instance and
+// results. Results are compared by method, value and params, a missing param
+// being equal to an empty one. Comments are ignored.
+func (p *Parsed) Equal(other *Parsed) bool {
+	return Diff(p, other) == ""
+}
+
+// Diff returns a human-readable description of the differences between a and
+// b, as defined by Parsed.Equal. An empty string is returned if they're equal.
+func Diff(a, b *Parsed) string {
+	if a == nil || b == nil {
+		if a == b {
+			return ""
+		}
+		return fmt.Sprintf("parsed: %v != %v", a, b)
+	}
+
+	var l []string
+	if a.Identifier != b.Identifier {
+		l = append(l, fmt.Sprintf("identifier: %q != %q", a.Identifier, b.Identifier))
+	}
+	if a.Instance != b.Instance {
+		l = append(l, fmt.Sprintf("instance: %v != %v", a.Instance, b.Instance))
+	}
+	if len(a.Results) != len(b.Results) {
+		l = append(l, fmt.Sprintf("number of results: %v != %v", len(a.Results), len(b.Results)))
+	}
+	for i := 0; i < len(a.Results) && i < len(b.Results); i++ {
+		for _, d := range diffResult(a.Results[i], b.Results[i]) {
+			l = append(l, fmt.Sprintf("result #%v: %v", i, d))
+		}
+	}
+	return strings.Join(l, "\n")
+}
+
+// diffResult returns the differences between the method, value and params of
+// two results.
+func diffResult(a, b Result) []string {
+	var l []string
+	if ma, mb := formatMethod(a), formatMethod(b); ma != mb {
+		l = append(l, fmt.Sprintf("method: %q != %q", ma, mb))
+	}
+
+	va, pa := resultParams(a)
+	vb, pb := resultParams(b)
+	if va != vb {
+		l = append(l, fmt.Sprintf("value: %q != %q", va, vb))
+	}
+
+	pa, pb = lowerKeys(pa), lowerKeys(pb)
+	keys := make([]string, 0, len(pa)+len(pb))
+	for k := range pa {
+		keys = append(keys, k)
+	}
+	for k := range pb {
+		if _, ok := pa[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if pa[k] != pb[k] {
+			l = append(l, fmt.Sprintf("%v: %q != %q", k, pa[k], pb[k]))
+		}
+	}
+
+	return l
+}
+
+// lowerKeys returns a copy of params with lowercase keys.
+func lowerKeys(params map[string]string) map[string]string {
+	m := make(map[string]string, len(params))
+	for k, v := range params {
+		m[strings.ToLower(k)] = v
+	}
+	return m
+}
+
+// MarshalText implements encoding.TextMarshaler. The header field value is
+// formatted with Format, prefixed with the instance if any.
+func (p *Parsed) MarshalText() ([]byte, error) {
+	s := FormatWithOptions(p.Identifier, p.Results, &FormatOptions{
+		Fold:     true,
+		Instance: p.Instance,
+	})
+	return []byte(s), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. The header field value is
+// parsed with Parse. If it is malformed, an error is returned and p is left
+// unchanged.
+func (p *Parsed) UnmarshalText(text []byte) error {
+	parsed := Parse(string(text))
+	if parsed.Error != nil {
+		return parsed.Error
+	}
+	*p = *parsed
+	return nil
+}
+
+// IdentifierMatches reports whether the authentication service identifier of p
+// is one of candidates, e.g. the host names of a cluster of mail servers.
+// Identifiers are compared case-insensitively, ignoring a trailing dot.
+func (p *Parsed) IdentifierMatches(candidates ...string) bool {
+	for _, id := range candidates {
+		if identifierEqual(p.Identifier, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// identifierEqual reports whether a and b are the same authentication service
+// identifier.
+func identifierEqual(a, b string) bool {
+	return strings.EqualFold(normalizeIdentifier(a), normalizeIdentifier(b))
+}
+
+// normalizeIdentifier strips the trailing dot of a fully-qualified domain
+// name, e.g. "mx.example.org.".
+func normalizeIdentifier(id string) string {
+	return strings.TrimSuffix(strings.TrimSpace(id), ".")
+}
+
+// TrustedResults returns the results of the outermost Authentication-Results
+// header field whose authentication service identifier is trustedID, i.e. the
+// first one in parsed, which is expected to be in header order. Identifiers are
+// compared as in FilterByIdentifier. ARC-Authentication-Results header fields
+// are ignored, see TrustedARCResults.
+//
+// Header fields further down claiming to come from trustedID were added
+// before, and may be forged.
+func TrustedResults(parsed []*Parsed, trustedID string) []Result {
+	for _, p := range FilterByIdentifier(parsed, trustedID) {
+		if p.Instance == 0 {
+			return p.Results
+		}
+	}
+	return nil
+}
+
+// TrustedARCResults returns the results of the ARC-Authentication-Results
+// header field with the highest instance whose authentication service
+// identifier is trustedID. Identifiers are compared as in FilterByIdentifier.
+//
+// The instance and the identifier aren't authenticated: anyone can add a
+// header field with a higher instance. Callers must validate the ARC chain
+// before trusting the results.
+func TrustedARCResults(parsed []*Parsed, trustedID string) []Result {
+	trusted := FilterByIdentifier(parsed, trustedID)
+
+	instance := 0
+	for _, p := range trusted {
+		if p.Instance > instance {
+			instance = p.Instance
+		}
+	}
+	if instance == 0 {
+		return nil
+	}
+
+	var l []Result
+	for _, p := range trusted {
+		if p.Instance == instance {
+			l = append(l, p.Results...)
+		}
+	}
+	return l
+}
+
+// SelectInstance returns the header field with the ARC instance, e.g. to
+// format a single ARC-Authentication-Results header field with MarshalText. nil
+// is returned if there is none.
+func SelectInstance(parsed []*Parsed, instance int) *Parsed {
+	for _, p := range parsed {
+		if p.Instance == instance {
+			return p
+		}
+	}
+	return nil
+}
+
+// Dedup returns results without duplicates, preserving the order in which
+// results first appear. Results are compared by method, value and params, as
+// in Parsed.Equal.
+func Dedup(results []Result) []Result {
+	var l []Result
+	for _, r := range results {
+		dup := false
+		for _, other := range l {
+			if len(diffResult(r, other)) == 0 {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			l = append(l, r)
+		}
+	}
+	return l
+}
+
+// ARCChainStatus returns the ARC chain validation status of the header field,
+// i.e. the arc.cv property of the first ARC result, or its value if the
+// property is missing. It returns an empty string if there is no ARC result.
+// Chain validation statuses are ResultNone, ResultFail or ResultPass, as
+// defined in RFC 8617 section 4.4.
+func (p *Parsed) ARCChainStatus() ResultValue {
+	r, ok := First[*ARCResult](p)
+	if !ok {
+		return ""
+	}
+	if r.ChainValidation != "" {
+		return r.ChainValidation
+	}
+	return r.Value
+}
+
+// ARCChain returns the ARC chain validation status of each
+// ARC-Authentication-Results header field, ordered by instance. Header fields
+// without an instance are ignored.
+func ARCChain(parsed []*Parsed) []ResultValue {
+	var arc []*Parsed
+	for _, p := range parsed {
+		if p.Instance > 0 {
+			arc = append(arc, p)
+		}
+	}
+	sort.SliceStable(arc, func(i, j int) bool {
+		return arc[i].Instance < arc[j].Instance
+	})
+
+	l := make([]ResultValue, len(arc))
+	for i, p := range arc {
+		l[i] = p.ARCChainStatus()
+	}
+	return l
+}
@@ -0,0 +1,346 @@
+package authres
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFirst(t *testing.T) {
+	p := Parse("example.com; spf=fail smtp.mailfrom=example.net;" +
+		" dkim=fail header.d=example.org; dkim=pass header.d=example.net")
+
+	spf, ok := First[*SPFResult](p)
+	if !ok || spf.From != "example.net" {
+		t.Errorf("First[*SPFResult]() = %v, %v, expected SPF result for example.net", spf, ok)
+	}
+
+	dkim, ok := First[*DKIMResult](p)
+	if !ok || dkim.Domain != "example.org" {
+		t.Errorf("First[*DKIMResult]() = %v, %v, expected DKIM result for example.org", dkim, ok)
+	}
+
+	if dmarc, ok := First[*DMARCResult](p); ok {
+		t.Errorf("First[*DMARCResult]() = %v, expected no result", dmarc)
+	}
+}
+
+func TestAll(t *testing.T) {
+	p := Parse("example.com; spf=fail smtp.mailfrom=example.net;" +
+		" dkim=fail header.d=example.org; dkim=pass header.d=example.net")
+
+	l := All[*DKIMResult](p)
+	if len(l) != 2 || l[0].Domain != "example.org" || l[1].Domain != "example.net" {
+		t.Errorf("All[*DKIMResult]() = %v, expected both DKIM results", l)
+	}
+
+	if l := All[*DMARCResult](p); len(l) != 0 {
+		t.Errorf("All[*DMARCResult]() = %v, expected no result", l)
+	}
+}
+
+func TestReasons(t *testing.T) {
+	p := Parse(`example.com; dkim=fail reason="bad signature" x-reason="key revoked" policy.reason=local header.d=example.org;` +
+		` x-custom=fail x-reason=vendor; spf=pass`)
+	want := [][]string{
+		{"bad signature", "local", "key revoked"},
+		{"vendor"},
+		nil,
+	}
+	if len(p.Results) != len(want) {
+		t.Fatalf("Expected %v results, got %v", len(want), p.Results)
+	}
+	for i, r := range p.Results {
+		if reasons := r.Reasons(); !reflect.DeepEqual(reasons, want[i]) {
+			t.Errorf("%v: Reasons() = %q, want %q", r, reasons, want[i])
+		}
+	}
+}
+
+func TestParsed_ByMethod(t *testing.T) {
+	p := Parse("example.com; dkim=fail header.d=example.org; spf=pass smtp.mailfrom=example.net;" +
+		" dkim=pass header.d=example.net; X-Custom=pass")
+
+	m := p.ByMethod()
+	want := map[string][]Result{
+		"dkim":     {p.Results[0], p.Results[2]},
+		"spf":      {p.Results[1]},
+		"x-custom": {p.Results[3]},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("ByMethod() = %v, want %v", m, want)
+	}
+}
+
+func TestParsed_Has(t *testing.T) {
+	p := Parse("example.com; dkim=fail header.d=example.org; X-Custom=pass")
+	if p.Len() != 2 || p.Empty() {
+		t.Errorf("Len() = %v, Empty() = %v, expected 2 and false", p.Len(), p.Empty())
+	}
+	for _, method := range []string{"dkim", "DKIM", "x-custom"} {
+		if !p.Has(method) {
+			t.Errorf("Has(%q) = false, expected true", method)
+		}
+	}
+	if p.Has("spf") {
+		t.Errorf("Has(%q) = true, expected false", "spf")
+	}
+
+	if p := Parse("example.com; none"); p.Len() != 0 || !p.Empty() {
+		t.Errorf("Len() = %v, Empty() = %v, expected 0 and true", p.Len(), p.Empty())
+	}
+	if p := Parse("example.com 2; none"); p.Empty() {
+		t.Errorf("Empty() = true for a malformed header field, expected false")
+	}
+}
+
+func TestParsed_AllPass(t *testing.T) {
+	tests := []struct {
+		v       string
+		methods []string
+		pass    bool
+	}{
+		{"example.com; spf=pass; dkim=pass; dmarc=pass", nil, true},
+		{"example.com; spf=pass; dkim=pass; dkim=pass; DMARC=pass", nil, true},
+		{"example.com; spf=pass; dkim=pass; dkim=fail; dmarc=pass", nil, false},
+		{"example.com; spf=pass; dkim=pass", nil, false},
+		{"example.com; spf=pass; dkim=pass", []string{"SPF", "dkim"}, true},
+		{"example.com; spf=pass; dkim=pass", []string{"arc"}, false},
+		{"example.com; none", nil, false},
+	}
+	for _, test := range tests {
+		if pass := Parse(test.v).AllPass(test.methods...); pass != test.pass {
+			t.Errorf("Parse(%q).AllPass(%q) = %v, want %v", test.v, test.methods, pass, test.pass)
+		}
+	}
+}
+
+func TestParsed_Conflicts(t *testing.T) {
+	p := Parse("example.com; dkim=pass header.d=example.org; spf=pass; dkim=fail header.d=example.net;" +
+		" x-custom=pass; X-Custom=neutral; dkim=pass header.d=example.com; spf=pass")
+	want := map[string][]ResultValue{
+		"dkim":     {ResultPass, ResultFail},
+		"x-custom": {ResultPass, ResultNeutral},
+	}
+	if got := p.Conflicts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Conflicts() = %v, want %v", got, want)
+	}
+
+	if got := Parse("example.com; dkim=pass; dkim=pass; spf=fail").Conflicts(); got != nil {
+		t.Errorf("Conflicts() = %v, want nil", got)
+	}
+}
+
+func TestParsed_Equal(t *testing.T) {
+	a := Parse("example.com; spf=pass smtp.helo=mail.example.net smtp.mailfrom=example.net;" +
+		" sender-id=pass header.From=example.net")
+	b := &Parsed{
+		Identifier: "example.com",
+		Results: []Result{
+			&SPFResult{Value: ResultPass, From: "example.net", Helo: "mail.example.net"},
+			&SenderIDResult{Value: ResultPass, HeaderKey: "from", HeaderValue: "example.net"},
+		},
+	}
+	if !a.Equal(b) {
+		t.Errorf("Expected parsed header fields to be equal, got diff:\n%v", Diff(a, b))
+	}
+
+	c := &Parsed{
+		Identifier: "example.org",
+		Results: []Result{
+			&SPFResult{Value: ResultFail, From: "example.net"},
+		},
+	}
+	if a.Equal(c) {
+		t.Errorf("Expected parsed header fields to be different")
+	}
+
+	want := "identifier: \"example.com\" != \"example.org\"\n" +
+		"number of results: 2 != 1\n" +
+		"result #0: value: \"pass\" != \"fail\"\n" +
+		"result #0: smtp.helo: \"mail.example.net\" != \"\""
+	if diff := Diff(a, c); diff != want {
+		t.Errorf("Expected diff to be \n%v\n but got \n%v", want, diff)
+	}
+}
+
+func TestParsed_MarshalText(t *testing.T) {
+	v := "i=2; example.com;\r\n\tspf=pass smtp.mailfrom=example.net"
+	var p Parsed
+	if err := p.UnmarshalText([]byte(v)); err != nil {
+		t.Fatalf("UnmarshalText() = %v", err)
+	}
+	if p.Instance != 2 || p.Identifier != "example.com" || len(p.Results) != 1 {
+		t.Errorf("Expected ARC header field with one result, got %+v", p)
+	}
+
+	b, err := p.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText() = %v", err)
+	}
+	if string(b) != v {
+		t.Errorf("MarshalText() = %v, want %v", string(b), v)
+	}
+
+	q := Parse("example.com; none")
+	if err := q.UnmarshalText([]byte("example.com 2; none")); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("UnmarshalText() = %v, want %v", err, ErrUnsupportedVersion)
+	}
+	if q.Identifier != "example.com" || !q.None {
+		t.Errorf("Expected UnmarshalText to leave the value unchanged, got %+v", q)
+	}
+}
+
+func TestParsed_IdentifierMatches(t *testing.T) {
+	tests := []struct {
+		identifier string
+		candidates []string
+		matches    bool
+	}{
+		{"mx.example.org", []string{"mx.example.org"}, true},
+		{"MX.Example.org", []string{"mx1.example.org", "mx.example.org"}, true},
+		{"mx.example.org.", []string{"mx.example.org"}, true},
+		{"mx.example.org", []string{"MX.EXAMPLE.ORG."}, true},
+		{"mx.example.org", []string{"mx.example.net"}, false},
+		{"mx.example.org", nil, false},
+		{"", []string{"mx.example.org"}, false},
+	}
+	for _, test := range tests {
+		p := &Parsed{Identifier: test.identifier}
+		if matches := p.IdentifierMatches(test.candidates...); matches != test.matches {
+			t.Errorf("IdentifierMatches(%q) with identifier %q = %v, expected %v", test.candidates, test.identifier, matches, test.matches)
+		}
+	}
+}
+
+func TestTrustedResults(t *testing.T) {
+	parsed, err := ParseMultiple([]string{
+		"mx.example.com; spf=pass smtp.mailfrom=example.net",
+		"mx.example.com; dkim=pass header.d=example.net",
+		"attacker.example.org; dmarc=pass header.from=example.net",
+	})
+	if err != nil {
+		t.Fatalf("ParseMultiple() = %v", err)
+	}
+	results := TrustedResults(parsed, "MX.example.com")
+	if len(results) != 1 || results[0] != parsed[0].Results[0] {
+		t.Errorf("Expected results of the first mx.example.com field, got %v", results)
+	}
+
+	// Forged field below the one added by mx.example.com
+	parsed, err = ParseMultiple([]string{
+		"attacker.example.org; dkim=pass header.d=example.net",
+		"mx.example.com; dkim=fail header.d=example.net",
+		"mx.example.com; dkim=pass header.d=example.net",
+	})
+	if err != nil {
+		t.Fatalf("ParseMultiple() = %v", err)
+	}
+	results = TrustedResults(parsed, "mx.example.com")
+	if len(results) != 1 || results[0] != parsed[1].Results[0] {
+		t.Errorf("Expected results of the outermost mx.example.com field, got %v", results)
+	}
+
+	// Forged ARC-Authentication-Results header field with a high instance
+	parsed, err = ParseMultiple([]string{
+		"i=50; mx.example.com; dkim=pass header.d=example.net",
+		"mx.example.com; dkim=fail header.d=example.net",
+	})
+	if err != nil {
+		t.Fatalf("ParseMultiple() = %v", err)
+	}
+	results = TrustedResults(parsed, "mx.example.com")
+	if len(results) != 1 || results[0] != parsed[1].Results[0] {
+		t.Errorf("Expected results of the Authentication-Results field, got %v", results)
+	}
+
+	if results := TrustedResults(parsed, "example.com"); results != nil {
+		t.Errorf("Expected no results for an unknown identifier, got %v", results)
+	}
+}
+
+func TestTrustedARCResults(t *testing.T) {
+	parsed, err := ParseMultiple([]string{
+		"i=2; mx.example.com; arc=pass",
+		"i=1; mx.example.com; arc=none",
+		"i=3; attacker.example.org; arc=pass",
+		"mx.example.com; dkim=pass",
+	})
+	if err != nil {
+		t.Fatalf("ParseMultiple() = %v", err)
+	}
+	results := TrustedARCResults(parsed, "mx.example.com")
+	if len(results) != 1 || results[0] != parsed[0].Results[0] {
+		t.Errorf("Expected results of instance 2, got %v", results)
+	}
+
+	if results := TrustedARCResults(parsed[3:], "mx.example.com"); results != nil {
+		t.Errorf("Expected no results without ARC-Authentication-Results, got %v", results)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	results := []Result{
+		&DKIMResult{Value: ResultPass, Domain: "example.com"},
+		&SPFResult{Value: ResultPass, From: "example.com"},
+		&DKIMResult{ResultBase: ResultBase{Comment: "again"}, Value: ResultPass, Domain: "example.com"},
+		&DKIMResult{Value: ResultPass, Domain: "example.org"},
+		&GenericResult{Method: "DKIM", Value: ResultPass, Params: map[string]string{"header.d": "example.com"}},
+		&SPFResult{Value: ResultFail, From: "example.com"},
+	}
+	want := []Result{results[0], results[1], results[3], results[5]}
+	if l := Dedup(results); !reflect.DeepEqual(l, want) {
+		t.Errorf("Dedup() = %v, want %v", l, want)
+	}
+}
+
+func TestARCChain(t *testing.T) {
+	parsed, err := ParseMultiple([]string{
+		"i=3; mx.example.com; arc=fail arc.cv=fail",
+		"mx.example.com; spf=pass smtp.mailfrom=example.net",
+		"i=1; relay.example.org; arc=none",
+		"i=2; relay.example.net; dkim=pass; arc=pass header.i=1",
+	})
+	if err != nil {
+		t.Fatalf("ParseMultiple() = %v", err)
+	}
+
+	if status := parsed[0].ARCChainStatus(); status != ResultFail {
+		t.Errorf("ARCChainStatus() = %q, want %q", status, ResultFail)
+	}
+	if status := parsed[1].ARCChainStatus(); status != "" {
+		t.Errorf("ARCChainStatus() = %q, want none", status)
+	}
+
+	want := []ResultValue{ResultNone, ResultPass, ResultFail}
+	if chain := ARCChain(parsed); !reflect.DeepEqual(chain, want) {
+		t.Errorf("ARCChain() = %v, want %v", chain, want)
+	}
+}
+
+func TestSelectInstance(t *testing.T) {
+	parsed, err := ParseMultiple([]string{
+		"i=2; relay.example.net; dkim=pass header.d=example.org; arc=pass header.i=1",
+		"mx.example.com; spf=pass smtp.mailfrom=example.net",
+		"i=1; relay.example.org; arc=none",
+	})
+	if err != nil {
+		t.Fatalf("ParseMultiple() = %v", err)
+	}
+
+	p := SelectInstance(parsed, 2)
+	if p != parsed[0] {
+		t.Fatalf("SelectInstance(2) = %v, want %v", p, parsed[0])
+	}
+	b, err := p.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText() = %v", err)
+	}
+	if want := "i=2; relay.example.net;\r\n\tdkim=pass header.d=example.org; arc=pass header.i=1"; string(b) != want {
+		t.Errorf("MarshalText() = %q, want %q", b, want)
+	}
+
+	if p := SelectInstance(parsed, 3); p != nil {
+		t.Errorf("SelectInstance(3) = %v, want nil", p)
+	}
+}
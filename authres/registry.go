@@ -0,0 +1,135 @@
+package authres
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var resultsMu sync.RWMutex
+
+// resultsGen is incremented each time results is modified, so that cached
+// header fields parsed with other methods can be detected.
+var resultsGen uint64
+
+// RegisterMethod registers a custom authentication method, so that Parse
+// creates results of this method with factory. Methods are case-insensitive.
+// Registering a built-in method replaces it.
+//
+// The results returned by factory must embed GenericResult, which is populated
+// with the value and params of the result. Custom result types should
+// implement Clone, since the one of GenericResult returns a *GenericResult:
+// CachingParser doesn't cache header fields containing results which don't.
+//
+//	type ScoreResult struct {
+//		authres.GenericResult
+//	}
+//
+//	func (r *ScoreResult) Score() string {
+//		return r.Params["policy.score"]
+//	}
+//
+//	func init() {
+//		authres.RegisterMethod("x-company-score", func() authres.Result {
+//			return new(ScoreResult)
+//		})
+//	}
+//
+// RegisterMethod is safe for concurrent use, but methods should be registered
+// at init time, before any header field is parsed.
+func RegisterMethod(name string, factory func() Result) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	results[strings.ToLower(name)] = factory
+	resultsGen++
+}
+
+// UnregisterMethod unregisters an authentication method. Results of this
+// method are parsed as GenericResult.
+func UnregisterMethod(name string) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	delete(results, strings.ToLower(name))
+	resultsGen++
+}
+
+// registryGeneration returns the current generation of the registered
+// methods.
+func registryGeneration() uint64 {
+	resultsMu.RLock()
+	defer resultsMu.RUnlock()
+	return resultsGen
+}
+
+// MethodInfo describes an authentication method.
+type MethodInfo struct {
+	// Name is the lowercase name of the method, e.g. "dkim".
+	Name string
+	// RFC is the specification defining the method, e.g. "RFC 6376". It's
+	// empty for methods which aren't defined by an RFC, such as BIMI or the
+	// methods registered with RegisterMethod.
+	RFC string
+	// ResultType is the type of the results of the method, e.g.
+	// *DKIMResult.
+	ResultType reflect.Type
+}
+
+// methodRFCs contains the specifications defining the built-in methods.
+var methodRFCs = map[string]string{
+	"auth":       "RFC 8601",
+	"dkim":       "RFC 6376",
+	"domainkeys": "RFC 4870",
+	"iprev":      "RFC 8601",
+	"sender-id":  "RFC 4406",
+	"spf":        "RFC 7208",
+	"dmarc":      "RFC 7489",
+	"dkim-adsp":  "RFC 5617",
+	"arc":        "RFC 8617",
+}
+
+// RegisteredMethods returns the built-in methods and the methods registered
+// with RegisterMethod, sorted by name.
+func RegisteredMethods() []MethodInfo {
+	resultsMu.RLock()
+	defer resultsMu.RUnlock()
+
+	l := make([]MethodInfo, 0, len(results))
+	for name, newResult := range results {
+		l = append(l, MethodInfo{
+			Name:       name,
+			RFC:        methodRFCs[name],
+			ResultType: reflect.TypeOf(newResult()),
+		})
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+	return l
+}
+
+func lookupMethod(method string) (newResultFunc, bool) {
+	resultsMu.RLock()
+	defer resultsMu.RUnlock()
+	newResult, ok := results[method]
+	return newResult, ok
+}
+
+// newMethodResult creates an empty result for the lowercase method.
+func newMethodResult(method string, version int) (Result, error) {
+	newResult, ok := lookupMethod(method)
+	if !ok {
+		return &GenericResult{Method: method}, nil
+	}
+
+	r := newResult()
+	if g, ok := r.(genericResult); ok {
+		if g := g.generic(); g.Method == "" {
+			g.Method = method
+		}
+	} else if version > 1 {
+		// All built-in methods are at version 1
+		return nil, ErrUnsupportedMethodVersion
+	}
+	return r, nil
+}
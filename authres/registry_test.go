@@ -0,0 +1,77 @@
+package authres
+
+import (
+	"reflect"
+	"testing"
+)
+
+type scoreResult struct {
+	GenericResult
+}
+
+func (r *scoreResult) score() string {
+	return r.Params["policy.score"]
+}
+
+func TestRegisterMethod(t *testing.T) {
+	RegisterMethod("X-Company-Score", func() Result {
+		return new(scoreResult)
+	})
+	defer UnregisterMethod("x-company-score")
+
+	v := "example.com; x-company-score=pass policy.score=4.5; x-other=pass"
+	p := Parse(v)
+	if p.Error != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", v, p.Error)
+	}
+	if len(p.Results) != 2 {
+		t.Fatalf("Parse(%q): expected 2 results, got %v", v, p.Results)
+	}
+	r, ok := p.Results[0].(*scoreResult)
+	if !ok {
+		t.Fatalf("Parse(%q): expected a custom result, got %T", v, p.Results[0])
+	}
+	if r.Method != "x-company-score" || r.Value != ResultPass || r.score() != "4.5" {
+		t.Errorf("Parse(%q): unexpected custom result %#v", v, r)
+	}
+	if _, ok := p.Results[1].(*GenericResult); !ok {
+		t.Errorf("Parse(%q): expected a generic result, got %T", v, p.Results[1])
+	}
+	if s := FormatWithOptions(p.Identifier, p.Results, &FormatOptions{}); s != v {
+		t.Errorf("Format() = %q, want %q", s, v)
+	}
+
+	UnregisterMethod("x-company-score")
+	if p := Parse(v); len(p.Results) != 2 {
+		t.Errorf("Parse(%q): expected 2 results, got %v", v, p.Results)
+	} else if _, ok := p.Results[0].(*GenericResult); !ok {
+		t.Errorf("Parse(%q): expected a generic result after unregistering, got %T", v, p.Results[0])
+	}
+}
+
+func TestRegisteredMethods(t *testing.T) {
+	RegisterMethod("x-company-score", func() Result {
+		return new(scoreResult)
+	})
+	defer UnregisterMethod("x-company-score")
+
+	m := make(map[string]MethodInfo)
+	for _, info := range RegisteredMethods() {
+		m[info.Name] = info
+	}
+
+	want := []MethodInfo{
+		{"dkim", "RFC 6376", reflect.TypeOf(&DKIMResult{})},
+		{"spf", "RFC 7208", reflect.TypeOf(&SPFResult{})},
+		{"bimi", "", reflect.TypeOf(&BIMIResult{})},
+		{"x-company-score", "", reflect.TypeOf(&scoreResult{})},
+	}
+	for _, info := range want {
+		if m[info.Name] != info {
+			t.Errorf("RegisteredMethods(): got %+v, want %+v", m[info.Name], info)
+		}
+	}
+	if len(m) != len(results) {
+		t.Errorf("RegisteredMethods(): got %v methods, want %v", len(m), len(results))
+	}
+}
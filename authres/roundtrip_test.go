@@ -0,0 +1,106 @@
+package authres
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTripCorpus contains header field values seen in the wild.
+var roundTripCorpus = []string{
+	// Gmail
+	"mx.google.com;\r\n" +
+		"       dkim=pass header.i=@example.com header.s=20230601 header.b=AbCdEf12;\r\n" +
+		"       spf=pass (google.com: domain of alice@example.com designates 209.85.220.41 as permitted sender) smtp.mailfrom=alice@example.com;\r\n" +
+		"       dmarc=pass (p=NONE sp=NONE dis=NONE) header.from=example.com",
+	"i=1; mx.google.com;\r\n" +
+		"       dkim=pass header.i=@example.com header.s=sel header.b=XyZ0;\r\n" +
+		"       arc=pass (i=1 spf=pass spfdomain=example.com dkim=pass dkdomain=example.com dmarc=pass fromdomain=example.com);\r\n" +
+		"       spf=pass (google.com: domain of bob@example.com designates 192.0.2.1 as permitted sender) smtp.mailfrom=bob@example.com",
+	// Microsoft, without authserv-id
+	"spf=pass (sender IP is 192.0.2.1) smtp.mailfrom=example.com; dkim=pass (signature was verified)\r\n" +
+		" header.d=example.com;dmarc=pass action=none header.from=example.com;compauth=pass reason=100",
+	// Fastmail
+	"mx6.messagingengine.com;\r\n" +
+		"    dkim=pass (2048-bit rsa key sha256) header.d=example.com header.i=@example.com header.b=AbCd header.a=rsa-sha256 header.s=fm1 x-bits=2048;\r\n" +
+		"    dmarc=pass policy.published-domain-policy=none policy.applied-disposition=none policy.evaluated-disposition=none (p=none,d=none,d.eval=none) policy.policy-from=p header.from=example.com;\r\n" +
+		"    iprev=pass smtp.remote-ip=192.0.2.1 (mail.example.com);\r\n" +
+		"    spf=pass smtp.mailfrom=alice@example.com smtp.helo=mail.example.com;\r\n" +
+		"    x-aligned-from=pass (Address match);\r\n" +
+		"    x-me-sender=none;\r\n" +
+		"    x-ptr=pass smtp.helo=mail.example.com policy.ptr=mail.example.com",
+	// Yahoo
+	"atlas-production.v2-mail-prod1-gq1.omega.yahoo.com;\r\n" +
+		" dkim=pass header.i=@example.com header.s=s1024;\r\n" +
+		" spf=pass smtp.mailfrom=example.com;\r\n" +
+		" dmarc=pass(p=REJECT) header.from=example.com;",
+	// RFC 8601 appendix B
+	"example.com;\r\n" +
+		"    auth=pass (cram-md5) smtp.auth=sender@example.net;\r\n" +
+		"    spf=pass smtp.mailfrom=example.net",
+	"example.com;\r\n" +
+		"    sender-id=pass header.from=example.com header.sender=list@example.com",
+	"example.com;\r\n" +
+		"    dkim=pass (good signature) header.d=example.com;\r\n" +
+		"    dkim=fail reason=\"signature verification failed; bad key\" header.d=example.com header.i=@example.com",
+	"example.org;\r\n" +
+		"    domainkeys=pass header.from=joe@example.com;\r\n" +
+		"    dkim-adsp=pass header.from=joe@example.com",
+	"mx.example.org;\r\n" +
+		"    bimi=pass header.d=example.com header.selector=default policy.authority=pass\r\n" +
+		"    policy.authority-uri=https://example.com/vmc.pem policy.indicator-uri=https://example.com/logo.svg",
+	"example.net; iprev=fail policy.iprev=192.0.2.200 dns.sec=no; auth=fail reason=\"bad password\" smtp.auth=bob",
+}
+
+// semanticResults returns the method, value and params of the results of a
+// header field value, ignoring comments, whitespace and params order.
+func semanticResults(v string) (id string, results []map[string]string) {
+	v, _ = unfold(v)
+	v, _ = stripComments(v)
+	p := Parse(v)
+	for _, seg := range splitQuoted(nil, token{s: v}, isSemicolon) {
+		fields := splitFields(nil, seg)
+		if len(fields) == 0 || !strings.Contains(fields[0].s, "=") {
+			continue
+		}
+		m := make(map[string]string)
+		for i, f := range fields {
+			k, v, err := parseParam(f.s)
+			if err != nil {
+				continue
+			}
+			if i == 0 {
+				k, v = "method "+k, strings.ToLower(v)
+			}
+			m[k] = v
+		}
+		results = append(results, m)
+	}
+	return p.Identifier, results
+}
+
+func TestRoundTrip_corpus(t *testing.T) {
+	for _, v := range roundTripCorpus {
+		p := Parse(v)
+		if p.Error != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", v, p.Error)
+			continue
+		}
+		b, err := p.MarshalText()
+		if err != nil {
+			t.Errorf("MarshalText(%q): unexpected error: %v", v, err)
+			continue
+		}
+		s := string(b)
+
+		wantID, want := semanticResults(v)
+		gotID, got := semanticResults(s)
+		if gotID != wantID || !reflect.DeepEqual(got, want) {
+			t.Errorf("Format(Parse(%q)) = %q: expected\n%v %v\n but got\n%v %v", v, s, wantID, want, gotID, got)
+		}
+
+		if b, _ := Parse(s).MarshalText(); string(b) != s {
+			t.Errorf("Format is not idempotent for %q: got %q then %q", v, s, b)
+		}
+	}
+}
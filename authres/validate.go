@@ -0,0 +1,120 @@
+package authres
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidResultValue is returned when a result value isn't allowed for an
+// authentication method.
+var ErrInvalidResultValue = errors.New("msgauth: invalid result value")
+
+// ParseResultValue parses a result value. The value is case-insensitive, and
+// must be one of the Result* constants.
+func ParseResultValue(s string) (ResultValue, error) {
+	v := ResultValue(strings.ToLower(strings.TrimSpace(s)))
+	switch v {
+	case ResultNone, ResultPass, ResultFail, ResultPolicy, ResultNeutral, ResultTempError, ResultPermError, ResultHardFail, ResultSoftFail:
+		return v, nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrInvalidResultValue, s)
+	}
+}
+
+// resultValues contains the result values allowed for each known
+// authentication method.
+var resultValues = map[string][]ResultValue{
+	// RFC 8601 section 2.7.4
+	"auth": {ResultNone, ResultPass, ResultFail, ResultTempError, ResultPermError},
+	// RFC 8601 section 2.7.1
+	"dkim":       {ResultNone, ResultPass, ResultFail, ResultPolicy, ResultNeutral, ResultTempError, ResultPermError},
+	"domainkeys": {ResultNone, ResultPass, ResultFail, ResultPolicy, ResultNeutral, ResultTempError, ResultPermError},
+	// RFC 8601 section 2.7.3
+	"iprev": {ResultPass, ResultFail, ResultTempError, ResultPermError},
+	// RFC 8601 section 2.7.2, hardfail is kept from RFC 5451
+	"sender-id": {ResultNone, ResultPass, ResultFail, ResultSoftFail, ResultNeutral, ResultTempError, ResultPermError, ResultHardFail},
+	"spf":       {ResultNone, ResultPass, ResultFail, ResultSoftFail, ResultNeutral, ResultTempError, ResultPermError, ResultHardFail},
+	// RFC 7489 section 11.2
+	"dmarc": {ResultNone, ResultPass, ResultFail, ResultTempError, ResultPermError},
+	// RFC 5617
+	"dkim-adsp": {ResultNone, ResultPass, "unknown", ResultFail, "discard", "nxdomain", ResultTempError, ResultPermError},
+	// draft-brand-indicators-for-message-identification
+	"bimi": {ResultNone, ResultPass, ResultFail, ResultTempError, "declined", "skipped"},
+	// RFC 8617
+	"arc": {ResultNone, ResultPass, ResultFail},
+}
+
+// ValidateResultValue checks that v is an allowed result value for method.
+// Unknown methods aren't checked.
+func ValidateResultValue(method string, v ResultValue) error {
+	allowed, ok := resultValues[strings.ToLower(method)]
+	if !ok {
+		return nil
+	}
+
+	for _, av := range allowed {
+		if v == av {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q for method %q", ErrInvalidResultValue, v, method)
+}
+
+var (
+	// ErrMissingIdentifier is returned by Parsed.Valid when the header field
+	// has no authentication service identifier, and by Parse when an
+	// ARC-Authentication-Results header field only contains an instance, e.g.
+	// "i=1".
+	ErrMissingIdentifier = errors.New("msgauth: missing authentication service identifier")
+	// ErrMissingProperty is returned by Parsed.Valid when a result lacks the
+	// property identifying what was authenticated.
+	ErrMissingProperty = errors.New("msgauth: missing property")
+)
+
+// requiredProperties contains, for each known authentication method, the
+// properties of which at least one must be present in passing results.
+var requiredProperties = map[string][]string{
+	"auth":       {"smtp.auth", "smtp.mailfrom"},
+	"dkim":       {"header.d", "header.i"},
+	"domainkeys": {"header.d", "header.from", "header.sender"},
+	"iprev":      {"policy.iprev"},
+	"spf":        {"smtp.mailfrom", "smtp.helo"},
+	"dmarc":      {"header.from"},
+	"dkim-adsp":  {"header.from"},
+	"bimi":       {"header.d"},
+}
+
+// Valid checks that the header field is well-formed: the identifier must not
+// be empty, the result values must be allowed for their methods (see
+// ValidateResultValue), and passing results must include the properties
+// identifying what was authenticated, e.g. header.d for DKIM. The returned
+// error joins all the problems found.
+func (p *Parsed) Valid() error {
+	var errs []error
+	if p.Identifier == "" {
+		errs = append(errs, ErrMissingIdentifier)
+	}
+	for i, r := range p.Results {
+		method := resultMethod(r)
+		value, params := resultParams(r)
+		if err := ValidateResultValue(method, value); err != nil {
+			errs = append(errs, fmt.Errorf("result #%v: %w", i, err))
+		}
+		if required, ok := requiredProperties[method]; ok && value == ResultPass && !hasAnyParam(params, required) {
+			errs = append(errs, fmt.Errorf("result #%v: %w: %v result requires one of %v", i, ErrMissingProperty, method, strings.Join(required, ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func hasAnyParam(params map[string]string, keys []string) bool {
+	for _, k := range keys {
+		for pk, v := range params {
+			if v != "" && strings.EqualFold(pk, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
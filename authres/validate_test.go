@@ -0,0 +1,75 @@
+package authres
+
+import (
+	"errors"
+	"testing"
+)
+
+var validateResultValueTests = []struct {
+	method string
+	value  ResultValue
+	valid  bool
+}{
+	{"spf", ResultPass, true},
+	{"spf", ResultSoftFail, true},
+	{"spf", ResultPolicy, false},
+	{"SPF", ResultHardFail, true},
+	{"dkim", ResultPolicy, true},
+	{"dkim", ResultSoftFail, false},
+	{"iprev", ResultNone, false},
+	{"dmarc", ResultNeutral, false},
+	{"bimi", "declined", true},
+	{"x-custom", "anything", true},
+}
+
+func TestValidateResultValue(t *testing.T) {
+	for _, test := range validateResultValueTests {
+		err := ValidateResultValue(test.method, test.value)
+		if test.valid && err != nil {
+			t.Errorf("ValidateResultValue(%q, %q) = %v, expected no error", test.method, test.value, err)
+		} else if !test.valid && !errors.Is(err, ErrInvalidResultValue) {
+			t.Errorf("ValidateResultValue(%q, %q) = %v, expected %v", test.method, test.value, err, ErrInvalidResultValue)
+		}
+	}
+}
+
+var parseResultValueTests = []struct {
+	s     string
+	value ResultValue
+	valid bool
+}{
+	{"pass", ResultPass, true},
+	{"PermError", ResultPermError, true},
+	{" softfail ", ResultSoftFail, true},
+	{"passed", "", false},
+	{"", "", false},
+}
+
+func TestParseResultValue(t *testing.T) {
+	for _, test := range parseResultValueTests {
+		v, err := ParseResultValue(test.s)
+		if test.valid && (err != nil || v != test.value) {
+			t.Errorf("ParseResultValue(%q) = %q, %v, expected %q", test.s, v, err, test.value)
+		} else if !test.valid && !errors.Is(err, ErrInvalidResultValue) {
+			t.Errorf("ParseResultValue(%q) = %q, %v, expected %v", test.s, v, err, ErrInvalidResultValue)
+		}
+	}
+}
+
+func TestParsed_Valid(t *testing.T) {
+	p := Parse("example.com; dkim=pass header.d=example.org; spf=fail; dmarc=pass header.from=example.org")
+	if err := p.Valid(); err != nil {
+		t.Errorf("Valid() = %v", err)
+	}
+
+	p = Parse("dkim=pass; spf=policy smtp.mailfrom=example.net; iprev=pass policy.iprev=192.0.2.1; x-custom=whatever")
+	err := p.Valid()
+	for _, want := range []error{ErrMissingIdentifier, ErrMissingProperty, ErrInvalidResultValue} {
+		if !errors.Is(err, want) {
+			t.Errorf("Valid() = %v, expected %v", err, want)
+		}
+	}
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 3 {
+		t.Errorf("Valid() = %v, expected 3 errors, got %v", err, n)
+	}
+}
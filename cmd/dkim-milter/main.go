@@ -289,7 +289,8 @@ func (s *session) Body(m *milter.Modifier) (milter.Response, error) {
 		})
 	}
 
-	v := authres.Format(identity, results)
+	// Don't fold, line endings are up to the MTA
+	v := authres.FormatWithOptions(identity, results, &authres.FormatOptions{})
 	if err := m.InsertHeader(0, "Authentication-Results", v); err != nil {
 		return nil, err
 	}